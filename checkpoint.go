@@ -0,0 +1,96 @@
+package tarantula
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// checkpoint records the inputs of a scan whose requests, retries included, have completed,
+// so an interrupted scan skips them when it is resumed
+type checkpoint struct {
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]struct{}
+}
+
+// openCheckpoint reads the completed inputs of path and opens it to append the next ones
+func openCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{
+		completed: make(map[string]struct{}),
+	}
+
+	if checkpointFile, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(checkpointFile)
+		for scanner.Scan() {
+			if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+				c.completed[line] = struct{}{}
+			}
+		}
+		checkpointFile.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+	return c, nil
+}
+
+func (c *checkpoint) isCompleted(inp input) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, exists := c.completed[checkpointKey(inp)]
+	return exists
+}
+
+// complete records inp, a line is written at once so a crash loses no completed input
+func (c *checkpoint) complete(inp input) error {
+	if c == nil {
+		return nil
+	}
+
+	key := checkpointKey(inp)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.completed[key]; exists {
+		return nil
+	}
+
+	c.completed[key] = struct{}{}
+	_, err := c.file.WriteString(key + "\n")
+	return err
+}
+
+func (c *checkpoint) close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// completeTarget records inp in the checkpoint of the scan and counts it as completed
+func (t *tarantula) completeTarget(cp *checkpoint, inp input) {
+	if err := cp.complete(inp); err != nil {
+		t.logger.Error("write checkpoint", "path", t.checkpointPath, "target", inp.Subdomain, "port", inp.Port, "error", err)
+	}
+	atomic.AddInt64(&t.stats.completed, 1)
+}
+
+func checkpointKey(inp input) string {
+	return strings.Join([]string{inp.Protocol, inp.Subdomain, strconv.Itoa(inp.Port), inp.Path}, "|")
+}
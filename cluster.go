@@ -0,0 +1,62 @@
+package tarantula
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/ghaini/tarantula/detector"
+)
+
+// DefaultClusterDistance is the most simhash bits in which the bodies of a cluster differ. the
+// simhashes of unrelated bodies differ in about 32 bits, short pages with a changed host in about 10
+const DefaultClusterDistance = 10
+
+// Cluster groups results with the same status code whose bodies are identical or whose simhashes
+// differ in at most maxDistance bits, to collapse the same page served by many assets like parking
+// and default pages. results without an http response, like services and dangling takeovers, are
+// left out. the largest clusters come first
+func Cluster(results []Result, maxDistance int) [][]Result {
+	type cluster struct {
+		leader  Result
+		results []Result
+	}
+
+	var clusters []*cluster
+	byHash := make(map[string]*cluster)
+	for _, r := range results {
+		if r.StatusCode == 0 {
+			continue
+		}
+
+		hashKey := strconv.Itoa(r.StatusCode) + "|" + r.BodyHash
+		if c, exists := byHash[hashKey]; exists {
+			c.results = append(c.results, r)
+			continue
+		}
+
+		var similar *cluster
+		for _, c := range clusters {
+			if c.leader.StatusCode == r.StatusCode && detector.SimhashDistance(c.leader.Simhash, r.Simhash) <= maxDistance {
+				similar = c
+				break
+			}
+		}
+
+		if similar == nil {
+			similar = &cluster{leader: r}
+			clusters = append(clusters, similar)
+		}
+		similar.results = append(similar.results, r)
+		byHash[hashKey] = similar
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].results) > len(clusters[j].results)
+	})
+
+	grouped := make([][]Result, 0, len(clusters))
+	for _, c := range clusters {
+		grouped = append(grouped, c.results)
+	}
+	return grouped
+}
@@ -4,10 +4,14 @@ package constants
 const (
 	HTTP  = "http"
 	HTTPS = "https"
+	// TCP is a service which speaks neither http nor tls
+	TCP = "tcp"
 )
 
 const TechnologiesFileAddress = "https://raw.githubusercontent.com/ghaini/tarantula/master/data/technologies.json"
 const DNSServerList = "https://raw.githubusercontent.com/ghaini/tarantula/master/data/resolvers.txt"
+const CDNFileAddress = "https://raw.githubusercontent.com/ghaini/tarantula/master/data/cdn.json"
+const TakeoverFileAddress = "https://raw.githubusercontent.com/ghaini/tarantula/master/data/takeover.json"
 
 var PortsProtocols = map[int]string{
 	80: HTTP,
@@ -0,0 +1,21 @@
+package data
+
+import (
+	_ "embed"
+)
+
+//go:embed cdn.json
+var cdnJSON []byte
+
+// CDNs is the built-in list of cdn cname suffixes and ip ranges of the embedded cdn.json,
+// used when ~/.tarantula/cdn.json can not be loaded
+var CDNs map[string]CDN
+
+func init() {
+	mustDecode(cdnJSON, &CDNs)
+}
+
+type CDN struct {
+	CNAMEs []string `json:"cnames"`
+	Ranges []string `json:"ranges"`
+}
@@ -0,0 +1,12 @@
+package data
+
+import (
+	"encoding/json"
+)
+
+// mustDecode decodes an embedded json list once at startup, a malformed list is a build error
+func mustDecode(data []byte, v interface{}) {
+	if err := json.Unmarshal(data, v); err != nil {
+		panic("data: decode embedded list: " + err.Error())
+	}
+}
@@ -0,0 +1,17 @@
+package data
+
+// PortPresets are named lists of common web ports
+var PortPresets = map[string][]int{
+	"top-10-web": {80, 443, 8080, 8443, 8000, 8888, 8081, 3000, 5000, 9443},
+	"top-100-web": {
+		80, 81, 82, 83, 84, 85, 88, 443, 444, 591, 593, 800,
+		801, 808, 981, 1080, 1311, 2082, 2083, 2086, 2087, 2095, 2096, 2480,
+		3000, 3001, 3128, 3333, 4000, 4001, 4002, 4100, 4443, 4444, 4567, 5000,
+		5001, 5280, 5281, 5601, 5800, 6543, 7000, 7001, 7002, 7443, 7474, 8000,
+		8001, 8008, 8060, 8069, 8080, 8081, 8082, 8083, 8088, 8090, 8091, 8095,
+		8118, 8123, 8172, 8181, 8222, 8243, 8280, 8281, 8333, 8337, 8443, 8500,
+		8530, 8531, 8834, 8880, 8888, 8983, 9000, 9001, 9043, 9060, 9080, 9090,
+		9091, 9200, 9443, 9502, 9800, 9981, 10000, 10250, 11371, 12443, 15672, 16080,
+		18091, 18092, 20720, 32000,
+	},
+}
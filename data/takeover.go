@@ -0,0 +1,23 @@
+package data
+
+import (
+	_ "embed"
+)
+
+//go:embed takeover.json
+var takeoverJSON []byte
+
+// TakeoverFingerprints is the built-in list of services vulnerable to subdomain takeover of the
+// embedded takeover.json, used when ~/.tarantula/takeover.json can not be loaded
+var TakeoverFingerprints []TakeoverFingerprint
+
+func init() {
+	mustDecode(takeoverJSON, &TakeoverFingerprints)
+}
+
+type TakeoverFingerprint struct {
+	Service  string   `json:"service"`
+	CNAMEs   []string `json:"cnames"`
+	Bodies   []string `json:"bodies"`
+	NXDomain bool     `json:"nxdomain"`
+}
@@ -0,0 +1,80 @@
+package detector
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"unicode"
+)
+
+// simhashShingle is the number of consecutive words hashed as one feature of a simhash
+const simhashShingle = 3
+
+// BodyHash returns the hex sha256 of a body
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// CountWords returns the number of whitespace separated words of a body
+func CountWords(body []byte) int {
+	return len(bytes.Fields(body))
+}
+
+// CountLines returns the number of lines of a body, a last line without newline included
+func CountLines(body []byte) int {
+	lines := bytes.Count(body, []byte("\n"))
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+// Simhash returns the 64 bit simhash of the lowercase word shingles of a body. similar bodies
+// have simhashes which differ in a few bits, see SimhashDistance
+func Simhash(body []byte) uint64 {
+	words := bytes.FieldsFunc(bytes.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	shingle := simhashShingle
+	if len(words) < shingle {
+		shingle = len(words)
+	}
+
+	var weights [64]int
+	for i := 0; i+shingle <= len(words); i++ {
+		h := fnv.New64a()
+		for _, word := range words[i : i+shingle] {
+			h.Write(word)
+			h.Write([]byte{' '})
+		}
+
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var simhash uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			simhash |= 1 << bit
+		}
+	}
+	return simhash
+}
+
+// SimhashDistance returns the number of different bits of two simhashes
+func SimhashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
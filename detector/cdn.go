@@ -0,0 +1,78 @@
+package detector
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ghaini/tarantula/data"
+)
+
+type CDN struct {
+	cnames map[string]string
+	ranges []cdnRange
+}
+
+type cdnRange struct {
+	name    string
+	network *net.IPNet
+}
+
+// NewCDN loads the cdn list from ~/.tarantula/cdn.json stored by UpdateLists, falling back to
+// the built-in list
+func NewCDN() *CDN {
+	cdns := data.CDNs
+	var loadedCDNs map[string]data.CDN
+	if loadListFile("cdn.json", &loadedCDNs) && len(loadedCDNs) > 0 {
+		cdns = loadedCDNs
+	}
+
+	c := &CDN{
+		cnames: make(map[string]string),
+	}
+	for name, cdn := range cdns {
+		for _, cname := range cdn.CNAMEs {
+			c.cnames[strings.ToLower(strings.Trim(cname, "."))] = name
+		}
+
+		for _, r := range cdn.Ranges {
+			_, network, err := net.ParseCIDR(r)
+			if err != nil {
+				continue
+			}
+			c.ranges = append(c.ranges, cdnRange{name: name, network: network})
+		}
+	}
+
+	return c
+}
+
+// Detect returns the cdn serving a host from its cname chain or ips, or an empty string
+func (c *CDN) Detect(cnames []string, ips []string) string {
+	if c == nil {
+		return ""
+	}
+
+	for _, cname := range cnames {
+		labels := strings.Split(strings.ToLower(strings.Trim(cname, ".")), ".")
+		for i := range labels {
+			if name, ok := c.cnames[strings.Join(labels[i:], ".")]; ok {
+				return name
+			}
+		}
+	}
+
+	for _, ip := range ips {
+		parsedIP := net.ParseIP(ip)
+		if parsedIP == nil {
+			continue
+		}
+
+		for _, r := range c.ranges {
+			if r.network.Contains(parsedIP) {
+				return r.name
+			}
+		}
+	}
+
+	return ""
+}
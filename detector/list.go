@@ -0,0 +1,73 @@
+package detector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ghaini/tarantula/constants"
+	"github.com/valyala/fasthttp"
+)
+
+// listDownloadTimeout bounds the download of a published list
+const listDownloadTimeout = 30 * time.Second
+
+// loadListFile decodes ~/.tarantula/fileName, stored by UpdateLists, into v
+func loadListFile(fileName string, v interface{}) bool {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+
+	listFile, err := os.Open(home + "/.tarantula/" + fileName)
+	if err != nil {
+		return false
+	}
+
+	defer listFile.Close()
+	return json.NewDecoder(listFile).Decode(v) == nil
+}
+
+// UpdateLists downloads the published cdn and takeover lists to ~/.tarantula, where NewCDN and
+// NewTakeover load them instead of the built-in lists
+func UpdateLists() error {
+	var errs []error
+	for fileName, address := range map[string]string{
+		"cdn.json":      constants.CDNFileAddress,
+		"takeover.json": constants.TakeoverFileAddress,
+	} {
+		if err := downloadListFile(address, fileName); err != nil {
+			errs = append(errs, fmt.Errorf("update %s: %w", fileName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// downloadListFile stores the list published at address as ~/.tarantula/fileName
+func downloadListFile(address, fileName string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	statusCode, resp, err := fasthttp.GetTimeout(nil, address, listDownloadTimeout)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != fasthttp.StatusOK {
+		return fmt.Errorf("%s answered %d", address, statusCode)
+	}
+
+	// a broken list would replace the built-in one
+	if !json.Valid(resp) {
+		return fmt.Errorf("%s is not a json list", address)
+	}
+
+	if err := os.MkdirAll(home+"/.tarantula", 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(home+"/.tarantula/"+fileName, resp, 0644)
+}
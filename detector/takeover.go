@@ -0,0 +1,101 @@
+package detector
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ghaini/tarantula/data"
+)
+
+type Takeover struct {
+	fingerprints []data.TakeoverFingerprint
+}
+
+// TakeoverMatch is a service which a subdomain points to but does not exist anymore
+type TakeoverMatch struct {
+	Service  string
+	Evidence string
+}
+
+// NewTakeover loads the fingerprints from ~/.tarantula/takeover.json stored by UpdateLists, falling
+// back to the built-in list
+func NewTakeover() *Takeover {
+	fingerprints := data.TakeoverFingerprints
+	var loadedFingerprints []data.TakeoverFingerprint
+	if loadListFile("takeover.json", &loadedFingerprints) && len(loadedFingerprints) > 0 {
+		fingerprints = loadedFingerprints
+	}
+
+	return &Takeover{
+		fingerprints: fingerprints,
+	}
+}
+
+// Detect matches the cname chain and the response body of a subdomain against the body signatures
+func (t *Takeover) Detect(cnames []string, body []byte) *TakeoverMatch {
+	if t == nil {
+		return nil
+	}
+
+	for _, fingerprint := range t.fingerprints {
+		cname := matchCNAME(cnames, fingerprint.CNAMEs)
+		if len(fingerprint.CNAMEs) > 0 && cname == "" {
+			continue
+		}
+
+		for _, signature := range fingerprint.Bodies {
+			if !bytes.Contains(body, []byte(signature)) {
+				continue
+			}
+
+			evidence := "body contains \"" + signature + "\""
+			if cname != "" {
+				evidence = "cname " + cname + ", " + evidence
+			}
+			return &TakeoverMatch{
+				Service:  fingerprint.Service,
+				Evidence: evidence,
+			}
+		}
+	}
+
+	return nil
+}
+
+// DetectDangling matches the cname chain of a subdomain which does not resolve to any ip
+// against the services which are vulnerable when their target does not exist
+func (t *Takeover) DetectDangling(cnames []string) *TakeoverMatch {
+	if t == nil {
+		return nil
+	}
+
+	for _, fingerprint := range t.fingerprints {
+		if !fingerprint.NXDomain {
+			continue
+		}
+
+		if cname := matchCNAME(cnames, fingerprint.CNAMEs); cname != "" {
+			return &TakeoverMatch{
+				Service:  fingerprint.Service,
+				Evidence: "cname " + cname + " does not resolve",
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchCNAME returns the first cname of the chain ending with one of suffixes
+func matchCNAME(cnames []string, suffixes []string) string {
+	for _, cname := range cnames {
+		cname = strings.ToLower(strings.Trim(cname, "."))
+		for _, suffix := range suffixes {
+			suffix = strings.ToLower(strings.Trim(suffix, "."))
+			if cname == suffix || strings.HasSuffix(cname, "."+suffix) {
+				return cname
+			}
+		}
+	}
+
+	return ""
+}
@@ -112,7 +112,6 @@ func (t *StringArray) UnmarshalJSON(data []byte) error {
 			*t = sa
 			return nil
 		}
-		fmt.Println(string(data))
 		return err
 	}
 	*t = StringArray{s}
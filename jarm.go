@@ -0,0 +1,51 @@
+package tarantula
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ghaini/tarantula/network"
+)
+
+// jarmCache keeps the JARM fingerprint of every scanned host:port, as each takes ten handshakes
+type jarmCache struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func newJARMCache() *jarmCache {
+	return &jarmCache{
+		hashes: make(map[string]string),
+	}
+}
+
+// jarm returns the JARM fingerprint of host on port, 443 if port is empty
+func (t *tarantula) jarm(ctx context.Context, host, port string) string {
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		portNumber = 443
+	}
+
+	key := net.JoinHostPort(host, strconv.Itoa(portNumber))
+	if ip := network.ConnectAddress(ctx, host); ip != "" {
+		// virtual hosts of different ips are different servers
+		key = ip + "|" + key
+	}
+
+	t.jarmCache.mu.Lock()
+	hash, exists := t.jarmCache.hashes[key]
+	t.jarmCache.mu.Unlock()
+	if exists {
+		return hash
+	}
+
+	hash = network.JARM(ctx, t.connectionDialer(), host, portNumber, time.Duration(t.timeout)*time.Second)
+
+	t.jarmCache.mu.Lock()
+	t.jarmCache.hashes[key] = hash
+	t.jarmCache.mu.Unlock()
+	return hash
+}
@@ -0,0 +1,67 @@
+package tarantula
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkPaths is the number of paths of one host requested by a scan of the keep-alive benchmark
+const benchmarkPaths = 500
+
+// BenchmarkKeepAlive scans many paths of one local host with and without KeepAlive, reporting the
+// connections opened per scan next to the time
+func BenchmarkKeepAlive(b *testing.B) {
+	for _, scheme := range []string{"http", "https"} {
+		for _, keepAlive := range []bool{false, true} {
+			name := scheme
+			if keepAlive {
+				name += "-keepalive"
+			}
+
+			b.Run(name, func(b *testing.B) {
+				benchmarkScan(b, scheme == "https", keepAlive)
+			})
+		}
+	}
+}
+
+func benchmarkScan(b *testing.B, withTLS bool, keepAlive bool) {
+	var connections int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&connections, 1)
+		}
+	}
+
+	if withTLS {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	defer srv.Close()
+
+	targets := make([]string, benchmarkPaths)
+	for i := range targets {
+		targets[i] = srv.URL + "/" + strconv.Itoa(i)
+	}
+
+	t := NewTarantula().MultiThread(20).SetTimeout(5)
+	if keepAlive {
+		t.KeepAlive(20)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for range t.GetAssetsChan("", targets) {
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(&connections))/float64(b.N), "conns/op")
+}
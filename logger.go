@@ -0,0 +1,27 @@
+package tarantula
+
+import (
+	"log/slog"
+)
+
+// Logger receives the debug traces of scans as a message followed by key-value pairs.
+// a *slog.Logger is a Logger
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// SlogLogger returns a Logger writing to handler, like slog.NewTextHandler(os.Stderr, nil)
+func SlogLogger(handler slog.Handler) Logger {
+	return slog.New(handler)
+}
+
+// nopLogger is the default Logger, discarding everything
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,175 @@
+package tarantula
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "tarantula"
+
+// error phases of failed requests
+const (
+	phaseDNS     = "dns"
+	phaseConnect = "connect"
+	phaseTLS     = "tls"
+	phaseRequest = "request"
+	phaseRead    = "read"
+)
+
+// metrics are the prometheus collectors of a tarantula, a nil metrics records nothing
+type metrics struct {
+	requests           *prometheus.CounterVec
+	errors             *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	technologyDuration prometheus.Histogram
+	activeWorkers      prometheus.Gauge
+	dnsCacheHits       prometheus.CounterFunc
+	dnsCacheMisses     prometheus.CounterFunc
+}
+
+func newMetrics(t *tarantula) *metrics {
+	return &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Responses received by status class.",
+		}, []string{"status_class"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_errors_total",
+			Help:      "Failed requests by the phase they failed in.",
+		}, []string{"phase"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time until the response headers of a request, failed ones included.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"scheme"}),
+		technologyDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "technology_detection_duration_seconds",
+			Help:      "Time of the technology detection of a response.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		activeWorkers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "active_workers",
+			Help:      "Workers scanning a target.",
+		}),
+		dnsCacheHits: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "dns_cache_hits_total",
+			Help:      "DNS lookups answered from the cache.",
+		}, func() float64 {
+			hits, _ := t.dnsCache.Counts()
+			return float64(hits)
+		}),
+		dnsCacheMisses: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "dns_cache_misses_total",
+			Help:      "DNS lookups sent to a dns server.",
+		}, func() float64 {
+			_, misses := t.dnsCache.Counts()
+			return float64(misses)
+		}),
+	}
+}
+
+func (m *metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requests, m.errors, m.requestDuration, m.technologyDuration, m.activeWorkers, m.dnsCacheHits, m.dnsCacheMisses,
+	}
+}
+
+// observeRequest records the duration of a request and its status class or the phase of err
+func (m *metrics) observeRequest(scheme string, start time.Time, statusCode int, err error) {
+	if m == nil {
+		return
+	}
+
+	m.requestDuration.WithLabelValues(scheme).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(errorPhase(err)).Inc()
+		return
+	}
+	m.requests.WithLabelValues(strconv.Itoa(statusCode/100) + "xx").Inc()
+}
+
+func (m *metrics) readError() {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(phaseRead).Inc()
+}
+
+func (m *metrics) observeTechnology(start time.Time) {
+	if m == nil {
+		return
+	}
+	m.technologyDuration.Observe(time.Since(start).Seconds())
+}
+
+func (m *metrics) workerStarted() {
+	if m == nil {
+		return
+	}
+	m.activeWorkers.Inc()
+}
+
+func (m *metrics) workerDone() {
+	if m == nil {
+		return
+	}
+	m.activeWorkers.Dec()
+}
+
+// errorPhase returns the phase a request failed in
+func errorPhase(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return phaseDNS
+	case errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr):
+		return phaseTLS
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return phaseConnect
+	}
+
+	return phaseRequest
+}
+
+// WithMetrics registers prometheus metrics of the scans on registerer: responses by status class,
+// errors by phase, request and technology detection durations, active workers and dns cache hits.
+// the metrics of several tarantulas need their own labels on a shared registry, like
+// prometheus.WrapRegistererWith(prometheus.Labels{"scanner": name}, registerer). a registration
+// error keeps the metrics off and is logged as an error when a scan starts
+func (t *tarantula) WithMetrics(registerer prometheus.Registerer) *tarantula {
+	m := newMetrics(t)
+	collectors := m.collectors()
+	for i, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			// metrics of another tarantula are registered on registerer, keep them
+			for _, registered := range collectors[:i] {
+				registerer.Unregister(registered)
+			}
+			t.configErrors = append(t.configErrors, fmt.Errorf("register metrics: %w", err))
+			return t
+		}
+	}
+
+	t.metrics = m
+	return t
+}
@@ -0,0 +1,90 @@
+package tarantula
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TestMetrics scans a local host and scrapes the metrics of the scan from a prometheus handler
+func TestMetrics(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	defer target.Close()
+
+	registry := prometheus.NewRegistry()
+	scanner := NewTarantula().SetTimeout(5).WithMetrics(registry)
+	for range scanner.GetAssetsChan("", []string{target.URL + "/", target.URL + "/missing"}) {
+	}
+
+	exporter := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer exporter.Close()
+
+	resp, err := http.Get(exporter.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, metric := range []string{
+		`tarantula_requests_total{status_class="2xx"} 1`,
+		`tarantula_requests_total{status_class="4xx"} 1`,
+		`tarantula_request_duration_seconds_count{scheme="http"} 2`,
+		`tarantula_active_workers 0`,
+	} {
+		if !strings.Contains(string(body), metric) {
+			t.Errorf("scrape misses %s in:\n%s", metric, body)
+		}
+	}
+}
+
+// TestMetricsRegistrationError registers the metrics of two tarantulas on one registry, without and
+// with their own labels
+func TestMetricsRegistrationError(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	NewTarantula().WithMetrics(registry)
+
+	logger := &recordingLogger{}
+	scanner := NewTarantula().SetLogger(logger).WithMetrics(registry)
+	if scanner.metrics != nil {
+		t.Error("metrics of a failed registration are recorded")
+	}
+
+	for range scanner.GetAssetsChan("", nil) {
+	}
+	if len(logger.errors) != 1 {
+		t.Errorf("logged %d errors, want the registration error", len(logger.errors))
+	}
+
+	shared := prometheus.NewRegistry()
+	for _, name := range []string{"first", "second"} {
+		wrapped := prometheus.WrapRegistererWith(prometheus.Labels{"scanner": name}, shared)
+		if NewTarantula().WithMetrics(wrapped).metrics == nil {
+			t.Errorf("metrics labeled %s are not registered", name)
+		}
+	}
+}
+
+type recordingLogger struct {
+	nopLogger
+	errors []string
+}
+
+func (l *recordingLogger) Error(msg string, _ ...any) {
+	l.errors = append(l.errors, msg)
+}
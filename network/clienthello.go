@@ -0,0 +1,282 @@
+package network
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+type ClientHello int
+
+const (
+	// GoClientHello is the ClientHello of crypto/tls built from the tls profile, the default
+	GoClientHello ClientHello = iota
+	ChromeClientHello
+	FirefoxClientHello
+	SafariClientHello
+	EdgeClientHello
+	IOSClientHello
+	// UserAgentClientHello mimics the browser of the user agent sent in each request
+	UserAgentClientHello
+)
+
+var clientHelloIDs = map[ClientHello]utls.ClientHelloID{
+	ChromeClientHello:  utls.HelloChrome_Auto,
+	FirefoxClientHello: utls.HelloFirefox_Auto,
+	SafariClientHello:  utls.HelloSafari_Auto,
+	EdgeClientHello:    utls.HelloEdge_Auto,
+	IOSClientHello:     utls.HelloIOS_Auto,
+}
+
+type clientHelloKey struct{}
+
+// JA3Conn is a tls connection which knows the JA3 string of the ClientHello it sent
+type JA3Conn struct {
+	*utls.UConn
+	ja3 string
+}
+
+func (c *JA3Conn) JA3() string {
+	return c.ja3
+}
+
+// ClientHelloOfUserAgent returns the ClientHello of the browser of userAgent, chrome if it is unknown
+func ClientHelloOfUserAgent(userAgent string) ClientHello {
+	switch {
+	case strings.Contains(userAgent, "Firefox/"):
+		return FirefoxClientHello
+	case strings.Contains(userAgent, "Edg/") || strings.Contains(userAgent, "Edge/"):
+		return EdgeClientHello
+	case strings.Contains(userAgent, "Chrome/") || strings.Contains(userAgent, "Chromium/"):
+		return ChromeClientHello
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad"):
+		return IOSClientHello
+	case strings.Contains(userAgent, "Safari/"):
+		return SafariClientHello
+	}
+
+	return ChromeClientHello
+}
+
+// WithClientHello returns a context whose tls connections send hello
+func WithClientHello(ctx context.Context, hello ClientHello) context.Context {
+	return context.WithValue(ctx, clientHelloKey{}, hello)
+}
+
+// ConnectionState returns the state of the connection in the form net/http reads it, so the
+// negotiated protocol selects http/2
+func (c *JA3Conn) ConnectionState() tls.ConnectionState {
+	state := c.UConn.ConnectionState()
+	return tls.ConnectionState{
+		Version:                    state.Version,
+		HandshakeComplete:          state.HandshakeComplete,
+		DidResume:                  state.DidResume,
+		CipherSuite:                state.CipherSuite,
+		NegotiatedProtocol:         state.NegotiatedProtocol,
+		NegotiatedProtocolIsMutual: true,
+		ServerName:                 state.ServerName,
+		PeerCertificates:           state.PeerCertificates,
+		VerifiedChains:             state.VerifiedChains,
+		OCSPResponse:               state.OCSPResponse,
+		TLSUnique:                  state.TLSUnique,
+	}
+}
+
+// ClientHelloDialer returns a DialTLSContext which dials with dialContext and handshakes with the
+// ClientHello of the context or else hello, offering the ALPN of httpVersion. the verification and
+// certificates of tlsConfig are kept while its versions and cipher suites are replaced by the ones of the browser
+func ClientHelloDialer(dialContext func(ctx context.Context, network, addr string) (net.Conn, error), tlsConfig *tls.Config, hello ClientHello, httpVersion HTTPVersion) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialContext = dialerOrDefault(dialContext)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		requestHello := hello
+		if contextHello, ok := ctx.Value(clientHelloKey{}).(ClientHello); ok {
+			requestHello = contextHello
+		}
+
+		helloID, ok := clientHelloIDs[requestHello]
+		if !ok {
+			helloID = utls.HelloChrome_Auto
+		}
+
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := dialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		spec, err := utls.UTLSIdToSpec(helloID)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		// ALPN is not part of JA3, offer only the protocols the transport speaks
+		for _, extension := range spec.Extensions {
+			if alpn, ok := extension.(*utls.ALPNExtension); ok {
+				alpn.AlpnProtocols = httpVersion.ALPN()
+			}
+		}
+
+		uconn := utls.UClient(conn, utlsConfig(tlsConfig, host), utls.HelloCustom)
+		if err := uconn.ApplyPreset(&spec); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if err := uconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		ja3 := ""
+		if uconn.HandshakeState.Hello != nil {
+			ja3 = JA3(uconn.HandshakeState.Hello.Raw)
+		}
+
+		return &JA3Conn{UConn: uconn, ja3: ja3}, nil
+	}
+}
+
+func utlsConfig(tlsConfig *tls.Config, serverName string) *utls.Config {
+	config := &utls.Config{
+		ServerName:         strings.Trim(serverName, "[]"),
+		InsecureSkipVerify: true,
+	}
+
+	if tlsConfig == nil {
+		return config
+	}
+
+	config.InsecureSkipVerify = tlsConfig.InsecureSkipVerify
+	config.RootCAs = tlsConfig.RootCAs
+	if tlsConfig.ServerName != "" {
+		config.ServerName = tlsConfig.ServerName
+	}
+
+	for _, certificate := range tlsConfig.Certificates {
+		config.Certificates = append(config.Certificates, utls.Certificate{
+			Certificate: certificate.Certificate,
+			PrivateKey:  certificate.PrivateKey,
+			Leaf:        certificate.Leaf,
+		})
+	}
+
+	return config
+}
+
+// JA3 returns the JA3 string of a raw ClientHello handshake message:
+// version,ciphers,extensions,curves,point formats with GREASE values removed
+func JA3(clientHello []byte) string {
+	// handshake type (1), length (3), version (2), random (32)
+	if len(clientHello) < 38 {
+		return ""
+	}
+
+	version := binary.BigEndian.Uint16(clientHello[4:6])
+	data := clientHello[38:]
+
+	// session id
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return ""
+	}
+	data = data[1+int(data[0]):]
+
+	if len(data) < 2 {
+		return ""
+	}
+	ciphersLength := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+ciphersLength {
+		return ""
+	}
+	ciphers := uint16List(data[2:2+ciphersLength], 2)
+	data = data[2+ciphersLength:]
+
+	// compression methods
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return ""
+	}
+	data = data[1+int(data[0]):]
+
+	var extensions, curves, pointFormats []string
+	if len(data) >= 2 {
+		data = data[2:]
+		for len(data) >= 4 {
+			extensionType := binary.BigEndian.Uint16(data)
+			extensionLength := int(binary.BigEndian.Uint16(data[2:]))
+			if len(data) < 4+extensionLength {
+				break
+			}
+			extension := data[4 : 4+extensionLength]
+			data = data[4+extensionLength:]
+
+			if isGREASE(extensionType) {
+				continue
+			}
+			extensions = append(extensions, strconv.Itoa(int(extensionType)))
+
+			switch extensionType {
+			case 10: // supported groups
+				if len(extension) >= 2 {
+					curves = uint16List(extension[2:], 2)
+				}
+			case 11: // ec point formats
+				if len(extension) >= 1 {
+					pointFormats = uint16List(extension[1:], 1)
+				}
+			}
+		}
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(version)),
+		strings.Join(ciphers, "-"),
+		strings.Join(extensions, "-"),
+		strings.Join(curves, "-"),
+		strings.Join(pointFormats, "-"),
+	}, ",")
+}
+
+// JA3Hash returns the md5 of a JA3 string, the usual form of JA3 fingerprints
+func JA3Hash(ja3 string) string {
+	if ja3 == "" {
+		return ""
+	}
+
+	sum := md5.Sum([]byte(ja3))
+	return hex.EncodeToString(sum[:])
+}
+
+// uint16List returns the decimal values of data read size bytes at a time, without GREASE values
+func uint16List(data []byte, size int) []string {
+	var values []string
+	for i := 0; i+size <= len(data); i += size {
+		value := uint16(data[i])
+		if size == 2 {
+			value = binary.BigEndian.Uint16(data[i:])
+		}
+
+		if isGREASE(value) {
+			continue
+		}
+		values = append(values, strconv.Itoa(int(value)))
+	}
+
+	return values
+}
+
+// isGREASE reports whether value is one of the reserved 0x?a?a values of rfc 8701
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
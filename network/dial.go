@@ -0,0 +1,16 @@
+package network
+
+import (
+	"context"
+	"net"
+)
+
+// dialerOrDefault returns dialContext, or the dialer of a zero net.Dialer if it is nil. every function
+// of this package taking a dialContext accepts a nil one, dialing directly
+func dialerOrDefault(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dialContext == nil {
+		dialer := &net.Dialer{}
+		return dialer.DialContext
+	}
+	return dialContext
+}
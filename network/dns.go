@@ -0,0 +1,307 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const resolvConfAddress = "/etc/resolv.conf"
+
+const (
+	// DNSCacheMaxTTL caps how long the cache keeps a record, whatever the ttl of its answers
+	DNSCacheMaxTTL = 5 * time.Minute
+	// dnsNegativeTTL is how long the cache keeps a record without answers
+	dnsNegativeTTL = 30 * time.Second
+)
+
+var ErrNoDNSServer = errors.New("no dns server available")
+
+// DefaultDNSServers are asked by lookups without dns servers when resolv.conf lists none
+var DefaultDNSServers = []string{"1.1.1.1", "8.8.8.8"}
+
+// DNSRecord keeps the answer of a host lookup
+type DNSRecord struct {
+	CNAMEs   []string
+	A        []string
+	AAAA     []string
+	Resolver string
+
+	// ttl is the lowest ttl of the answers
+	ttl time.Duration
+}
+
+// IPs returns all the A and AAAA records of the lookup
+func (d *DNSRecord) IPs() []string {
+	return append(append([]string{}, d.A...), d.AAAA...)
+}
+
+// LookupDNS asks a random server of dnsServers (or the system dns servers, or else DefaultDNSServers, if none is given)
+// for the A and AAAA records of host, keeping the whole CNAME chain of the answers. a name error
+// returns the answers received, like the cname chain of a dangling host, other errors fail
+func LookupDNS(ctx context.Context, host string, dnsServers []string) (*DNSRecord, error) {
+	return LookupDNSThrough(ctx, host, dnsServers, nil)
+}
+
+// LookupDNSThrough is LookupDNS sending the queries over tcp connections of dialContext, like the
+// dialer of a proxy, so they do not leave the proxy. a nil dialContext queries directly over udp
+func LookupDNSThrough(ctx context.Context, host string, dnsServers []string, dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) (*DNSRecord, error) {
+	if len(dnsServers) == 0 {
+		dnsServers = SystemDNSServers()
+	}
+
+	if len(dnsServers) == 0 {
+		dnsServers = DefaultDNSServers
+	}
+
+	if len(dnsServers) == 0 {
+		return nil, ErrNoDNSServer
+	}
+
+	server := dnsServers[rand.Intn(len(dnsServers))]
+	record := &DNSRecord{
+		Resolver: server,
+	}
+
+	answered := false
+	seenCNAMEs := make(map[string]struct{})
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := exchangeDNS(ctx, dialContext, server, host, qtype)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, answer := range answers {
+			ttl := time.Duration(answer.Header.TTL) * time.Second
+			if !answered || ttl < record.ttl {
+				record.ttl = ttl
+				answered = true
+			}
+
+			switch body := answer.Body.(type) {
+			case *dnsmessage.CNAMEResource:
+				cname := strings.TrimSuffix(body.CNAME.String(), ".")
+				if _, exists := seenCNAMEs[cname]; !exists {
+					seenCNAMEs[cname] = struct{}{}
+					record.CNAMEs = append(record.CNAMEs, cname)
+				}
+			case *dnsmessage.AResource:
+				record.A = append(record.A, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				record.AAAA = append(record.AAAA, net.IP(body.AAAA[:]).String())
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// SystemDNSServers returns the name servers of resolv.conf
+func SystemDNSServers() []string {
+	resolvConf, err := os.Open(resolvConfAddress)
+	if err != nil {
+		return nil
+	}
+	defer resolvConf.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(resolvConf)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+
+	return servers
+}
+
+func exchangeDNS(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), server, host string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               uint16(rand.Intn(1 << 16)),
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := "udp"
+	if dialContext != nil {
+		// proxies carry only tcp
+		protocol = "tcp"
+	}
+
+	response, err := exchangeDNSPacket(ctx, dialContext, protocol, server, packed)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(response); err != nil {
+		return nil, err
+	}
+
+	if msg.Header.Truncated && protocol == "udp" {
+		response, err = exchangeDNSPacket(ctx, dialContext, "tcp", server, packed)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := msg.Unpack(response); err != nil {
+			return nil, err
+		}
+	}
+
+	if msg.Header.ID != query.Header.ID {
+		return nil, errors.New("dns response id mismatch")
+	}
+
+	if msg.Header.RCode != dnsmessage.RCodeSuccess && msg.Header.RCode != dnsmessage.RCodeNameError {
+		return nil, fmt.Errorf("dns server %s answered %s", server, msg.Header.RCode)
+	}
+
+	return msg.Answers, nil
+}
+
+func exchangeDNSPacket(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), network, server string, packet []byte) ([]byte, error) {
+	dialContext = dialerOrDefault(dialContext)
+
+	conn, err := dialContext(ctx, network, net.JoinHostPort(server, "53"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(packet); err != nil {
+			return nil, err
+		}
+
+		response := make([]byte, 4096)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		return response[:n], nil
+	}
+
+	// dns over tcp prefixes every message with its length
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(packet)))
+	if _, err := conn.Write(append(length, packet...)); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// DNSCache keeps the lookups of hosts for the ttl of their answers, at most DNSCacheMaxTTL
+type DNSCache struct {
+	mu          sync.Mutex
+	records     map[string]cachedDNSRecord
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	hits        int64
+	misses      int64
+}
+
+type cachedDNSRecord struct {
+	record  *DNSRecord
+	expires time.Time
+}
+
+func NewDNSCache() *DNSCache {
+	return &DNSCache{
+		records: make(map[string]cachedDNSRecord),
+	}
+}
+
+// SetDialer sends the lookups through dialContext, see LookupDNSThrough
+func (c *DNSCache) SetDialer(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	c.mu.Lock()
+	c.dialContext = dialContext
+	c.mu.Unlock()
+}
+
+// Counts returns the number of lookups answered from the cache and the ones looked up
+func (c *DNSCache) Counts() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Lookup returns the cached record of host or looks it up with LookupDNSThrough. failed lookups are not cached
+func (c *DNSCache) Lookup(ctx context.Context, host string, dnsServers []string) (*DNSRecord, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	c.mu.Lock()
+	cached, exists := c.records[host]
+	if exists && time.Now().After(cached.expires) {
+		delete(c.records, host)
+		exists = false
+	}
+
+	if exists {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	dialContext := c.dialContext
+	c.mu.Unlock()
+	if exists {
+		return cached.record, nil
+	}
+
+	record, err := LookupDNSThrough(ctx, host, dnsServers, dialContext)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := record.ttl
+	if len(record.CNAMEs)+len(record.A)+len(record.AAAA) == 0 {
+		ttl = dnsNegativeTTL
+	}
+	if ttl > DNSCacheMaxTTL {
+		ttl = DNSCacheMaxTTL
+	}
+
+	c.mu.Lock()
+	c.records[host] = cachedDNSRecord{record: record, expires: time.Now().Add(ttl)}
+	c.mu.Unlock()
+	return record, nil
+}
@@ -0,0 +1,49 @@
+package network
+
+import (
+	"net/http"
+)
+
+type HTTPVersion int
+
+const (
+	// HTTP1Only speaks only http/1.1, the default
+	HTTP1Only HTTPVersion = iota
+	// AutoHTTPVersion negotiates http/2 or http/1.1 with ALPN over tls and speaks http/1.1 in cleartext
+	AutoHTTPVersion
+	// HTTP2Only speaks http/2 over tls and h2c with prior knowledge in cleartext
+	HTTP2Only
+)
+
+// ALPN returns the application protocols offered in the tls handshake for version
+func (v HTTPVersion) ALPN() []string {
+	switch v {
+	case AutoHTTPVersion:
+		return []string{"h2", "http/1.1"}
+	case HTTP2Only:
+		return []string{"h2"}
+	}
+
+	return []string{"http/1.1"}
+}
+
+// SetHTTPVersion sets the protocols of transport to the ones of version. a custom dialer or tls
+// config disables the automatic http/2 of net/http, so the protocols are always set explicitly
+func SetHTTPVersion(transport *http.Transport, version HTTPVersion) {
+	protocols := new(http.Protocols)
+	switch version {
+	case AutoHTTPVersion:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	case HTTP2Only:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+	}
+
+	transport.Protocols = protocols
+	if transport.TLSClientConfig != nil {
+		transport.TLSClientConfig.NextProtos = version.ALPN()
+	}
+}
@@ -0,0 +1,63 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+type IPVersion int
+
+const (
+	AnyIPVersion IPVersion = iota
+	IPv4Only
+	IPv6Only
+	PreferIPv4
+	PreferIPv6
+)
+
+// IPVersionDialer restricts or orders the address families dialContext connects to
+func IPVersionDialer(dialContext func(ctx context.Context, network, addr string) (net.Conn, error), version IPVersion) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialContext = dialerOrDefault(dialContext)
+
+	switch version {
+	case IPv4Only:
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialContext(ctx, network+"4", addr)
+		}
+	case IPv6Only:
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialContext(ctx, network+"6", addr)
+		}
+	case PreferIPv4, PreferIPv6:
+		first, second := "4", "6"
+		if version == PreferIPv6 {
+			first, second = "6", "4"
+		}
+
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialContext(ctx, network+first, addr)
+			if err == nil {
+				return conn, nil
+			}
+			return dialContext(ctx, network+second, addr)
+		}
+	}
+
+	return dialContext
+}
+
+// JoinHostPort is net.JoinHostPort accepting ipv6 literals with or without brackets
+func JoinHostPort(host, port string) string {
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// HostLiteral brackets ipv6 literals for use as the host of an url
+func HostLiteral(host string) string {
+	trimmedHost := strings.Trim(host, "[]")
+	if ip := net.ParseIP(trimmedHost); ip != nil && ip.To4() == nil {
+		return "[" + trimmedHost + "]"
+	}
+
+	return host
+}
@@ -0,0 +1,416 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	mrand "math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Credits: https://github.com/salesforce/jarm
+
+const jarmResponseSize = 1484
+
+type jarmProbe struct {
+	version          string
+	tls13Ciphers     bool
+	cipherOrder      string
+	grease           bool
+	rareALPN         bool
+	supportedVersion string
+	extensionOrder   string
+}
+
+var jarmProbes = []jarmProbe{
+	{"TLS_1.2", true, "FORWARD", false, false, "1.2_SUPPORT", "REVERSE"},
+	{"TLS_1.2", true, "REVERSE", false, false, "1.2_SUPPORT", "FORWARD"},
+	{"TLS_1.2", true, "TOP_HALF", false, false, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.2", true, "BOTTOM_HALF", false, true, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.2", true, "MIDDLE_OUT", true, true, "NO_SUPPORT", "REVERSE"},
+	{"TLS_1.1", true, "FORWARD", false, false, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.3", true, "FORWARD", false, false, "1.3_SUPPORT", "REVERSE"},
+	{"TLS_1.3", true, "REVERSE", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"TLS_1.3", false, "FORWARD", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"TLS_1.3", true, "MIDDLE_OUT", true, false, "1.3_SUPPORT", "REVERSE"},
+}
+
+var jarmCiphers = []uint16{
+	0x0016, 0x0033, 0x0067, 0xc09e, 0xc0a2, 0x009e, 0x0039, 0x006b, 0xc09f, 0xc0a3, 0x009f, 0x0045,
+	0x00be, 0x0088, 0x00c4, 0x009a, 0xc008, 0xc009, 0xc023, 0xc0ac, 0xc0ae, 0xc02b, 0xc00a, 0xc024,
+	0xc0ad, 0xc0af, 0xc02c, 0xc072, 0xc073, 0xcca9, 0x1302, 0x1301, 0xcc14, 0xc007, 0xc012, 0xc013,
+	0xc027, 0xc02f, 0xc014, 0xc028, 0xc030, 0xc060, 0xc061, 0xc076, 0xc077, 0xcca8, 0x1305, 0x1304,
+	0x1303, 0xcc13, 0xc011, 0x000a, 0x002f, 0x003c, 0xc09c, 0xc0a0, 0x009c, 0x0035, 0x003d, 0xc09d,
+	0xc0a1, 0x009d, 0x0041, 0x00ba, 0x0084, 0x00c0, 0x0007, 0x0004, 0x0005,
+}
+
+// jarmHashCiphers is the order of the ciphers in the hash, tls 1.3 ciphers last
+var jarmHashCiphers = []uint16{
+	0x0004, 0x0005, 0x0007, 0x000a, 0x0016, 0x002f, 0x0033, 0x0035, 0x0039, 0x003c, 0x003d, 0x0041,
+	0x0045, 0x0067, 0x006b, 0x0084, 0x0088, 0x009a, 0x009c, 0x009d, 0x009e, 0x009f, 0x00ba, 0x00be,
+	0x00c0, 0x00c4, 0xc007, 0xc008, 0xc009, 0xc00a, 0xc011, 0xc012, 0xc013, 0xc014, 0xc023, 0xc024,
+	0xc027, 0xc028, 0xc02b, 0xc02c, 0xc02f, 0xc030, 0xc060, 0xc061, 0xc072, 0xc073, 0xc076, 0xc077,
+	0xc09c, 0xc09d, 0xc09e, 0xc09f, 0xc0a0, 0xc0a1, 0xc0a2, 0xc0a3, 0xc0ac, 0xc0ad, 0xc0ae, 0xc0af,
+	0xcc13, 0xcc14, 0xcca8, 0xcca9, 0x1301, 0x1302, 0x1303, 0x1304, 0x1305,
+}
+
+var jarmALPNs = []string{"http/0.9", "http/1.0", "http/1.1", "spdy/1", "spdy/2", "spdy/3", "h2", "h2c", "hq"}
+
+var jarmRareALPNs = []string{"http/0.9", "http/1.0", "spdy/1", "spdy/2", "spdy/3", "h2c", "hq"}
+
+// JARM returns the JARM fingerprint of the tls server at host:port, sending host as SNI like
+// the reference scanner does even for ips
+func JARM(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), host string, port int, timeout time.Duration) string {
+	dialContext = dialerOrDefault(dialContext)
+
+	host = strings.Trim(host, "[]")
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	responses := make([]string, len(jarmProbes))
+	for i, probe := range jarmProbes {
+		responses[i] = jarmResponse(ctx, dialContext, address, probe.clientHello(host), timeout)
+	}
+
+	return jarmHash(responses)
+}
+
+func jarmResponse(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), address string, clientHello []byte, timeout time.Duration) string {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", address)
+	if err != nil {
+		return "|||"
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(clientHello); err != nil {
+		return "|||"
+	}
+
+	response := make([]byte, jarmResponseSize)
+	n, err := conn.Read(response)
+	if err != nil && n == 0 {
+		return "|||"
+	}
+
+	return parseServerHello(response[:n])
+}
+
+func (p jarmProbe) clientHello(serverName string) []byte {
+	recordVersion, helloVersion := []byte{0x03, 0x03}, []byte{0x03, 0x03}
+	switch p.version {
+	case "TLS_1.3":
+		recordVersion = []byte{0x03, 0x01}
+	case "TLS_1.1":
+		recordVersion, helloVersion = []byte{0x03, 0x02}, []byte{0x03, 0x02}
+	}
+
+	hello := append([]byte{}, helloVersion...)
+	random := make([]byte, 32)
+	rand.Read(random)
+	hello = append(hello, random...)
+
+	sessionID := make([]byte, 32)
+	rand.Read(sessionID)
+	hello = append(hello, byte(len(sessionID)))
+	hello = append(hello, sessionID...)
+
+	var ciphers []uint16
+	for _, cipher := range jarmCiphers {
+		if !p.tls13Ciphers && cipher>>8 == 0x13 {
+			continue
+		}
+		ciphers = append(ciphers, cipher)
+	}
+	ciphers = jarmOrder(ciphers, p.cipherOrder)
+	if p.grease {
+		ciphers = append([]uint16{jarmGREASE()}, ciphers...)
+	}
+	hello = appendUint16(hello, uint16(len(ciphers)*2))
+	for _, cipher := range ciphers {
+		hello = appendUint16(hello, cipher)
+	}
+
+	// one compression method, null
+	hello = append(hello, 0x01, 0x00)
+	hello = append(hello, p.extensions(serverName)...)
+
+	handshake := []byte{0x01, 0x00}
+	handshake = appendUint16(handshake, uint16(len(hello)))
+	handshake = append(handshake, hello...)
+
+	record := append([]byte{0x16}, recordVersion...)
+	record = appendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+func (p jarmProbe) extensions(serverName string) []byte {
+	var extensions []byte
+	if p.grease {
+		extensions = appendUint16(extensions, jarmGREASE())
+		extensions = append(extensions, 0x00, 0x00)
+	}
+
+	// server name
+	extensions = append(extensions, 0x00, 0x00)
+	extensions = appendUint16(extensions, uint16(len(serverName)+5))
+	extensions = appendUint16(extensions, uint16(len(serverName)+3))
+	extensions = append(extensions, 0x00)
+	extensions = appendUint16(extensions, uint16(len(serverName)))
+	extensions = append(extensions, serverName...)
+
+	// extended master secret, max fragment length, renegotiation info, supported groups,
+	// ec point formats and session ticket
+	extensions = append(extensions, 0x00, 0x17, 0x00, 0x00)
+	extensions = append(extensions, 0x00, 0x01, 0x00, 0x01, 0x01)
+	extensions = append(extensions, 0xff, 0x01, 0x00, 0x01, 0x00)
+	extensions = append(extensions, 0x00, 0x0a, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19)
+	extensions = append(extensions, 0x00, 0x0b, 0x00, 0x02, 0x01, 0x00)
+	extensions = append(extensions, 0x00, 0x23, 0x00, 0x00)
+
+	// application layer protocol negotiation
+	alpns := jarmALPNs
+	if p.rareALPN {
+		alpns = jarmRareALPNs
+	}
+	var alpnList []byte
+	for _, alpn := range jarmOrderStrings(alpns, p.extensionOrder) {
+		alpnList = append(alpnList, byte(len(alpn)))
+		alpnList = append(alpnList, alpn...)
+	}
+	extensions = append(extensions, 0x00, 0x10)
+	extensions = appendUint16(extensions, uint16(len(alpnList)+2))
+	extensions = appendUint16(extensions, uint16(len(alpnList)))
+	extensions = append(extensions, alpnList...)
+
+	// signature algorithms
+	extensions = append(extensions, 0x00, 0x0d, 0x00, 0x14, 0x00, 0x12, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01,
+		0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01, 0x02, 0x01)
+
+	// key share
+	var keyShare []byte
+	if p.grease {
+		keyShare = appendUint16(keyShare, jarmGREASE())
+		keyShare = append(keyShare, 0x00, 0x01, 0x00)
+	}
+	keyShare = append(keyShare, 0x00, 0x1d, 0x00, 0x20)
+	key := make([]byte, 32)
+	rand.Read(key)
+	keyShare = append(keyShare, key...)
+	extensions = append(extensions, 0x00, 0x33)
+	extensions = appendUint16(extensions, uint16(len(keyShare)+2))
+	extensions = appendUint16(extensions, uint16(len(keyShare)))
+	extensions = append(extensions, keyShare...)
+
+	// psk key exchange modes
+	extensions = append(extensions, 0x00, 0x2d, 0x00, 0x02, 0x01, 0x01)
+
+	if p.version == "TLS_1.3" || p.supportedVersion == "1.2_SUPPORT" {
+		versions := []uint16{0x0301, 0x0302, 0x0303, 0x0304}
+		if p.supportedVersion == "1.2_SUPPORT" {
+			versions = versions[:3]
+		}
+		versions = jarmOrder(versions, p.extensionOrder)
+		if p.grease {
+			versions = append([]uint16{jarmGREASE()}, versions...)
+		}
+
+		extensions = append(extensions, 0x00, 0x2b)
+		extensions = appendUint16(extensions, uint16(len(versions)*2+1))
+		extensions = append(extensions, byte(len(versions)*2))
+		for _, version := range versions {
+			extensions = appendUint16(extensions, version)
+		}
+	}
+
+	return appendUint16(nil, uint16(len(extensions)), extensions...)
+}
+
+// parseServerHello returns "cipher|version|alpn|extensions" of a ServerHello record
+func parseServerHello(data []byte) (result string) {
+	defer func() {
+		// a truncated or malformed response
+		if recover() != nil {
+			result = "|||"
+		}
+	}()
+
+	if len(data) < 6 || data[0] != 0x16 || data[5] != 0x02 {
+		return "|||"
+	}
+
+	serverHelloLength := int(binary.BigEndian.Uint16(data[3:5]))
+	counter := int(data[43])
+	selectedCipher := hex.EncodeToString(data[counter+44 : counter+46])
+	version := hex.EncodeToString(data[9:11])
+	return selectedCipher + "|" + version + "|" + parseServerHelloExtensions(data, counter, serverHelloLength)
+}
+
+func parseServerHelloExtensions(data []byte, counter, serverHelloLength int) (result string) {
+	defer func() {
+		if recover() != nil {
+			result = "|"
+		}
+	}()
+
+	if data[counter+47] == 11 {
+		return "|"
+	} else if string(data[counter+50:counter+53]) == "\x0e\xac\x0b" || string(data[82:85]) == "\x0f\xf0\x0b" {
+		return "|"
+	} else if counter+42 >= serverHelloLength {
+		return "|"
+	}
+
+	count := 49 + counter
+	length := int(binary.BigEndian.Uint16(data[counter+47 : counter+49]))
+	maximum := length + count - 1
+	var types []string
+	alpn := ""
+	for count < maximum {
+		extensionType := data[count : count+2]
+		extensionLength := int(binary.BigEndian.Uint16(data[count+2 : count+4]))
+		value := data[count+4 : count+4+extensionLength]
+		if alpn == "" && extensionType[0] == 0x00 && extensionType[1] == 0x10 && len(value) > 3 {
+			alpn = string(value[3:])
+		}
+
+		types = append(types, hex.EncodeToString(extensionType))
+		count += extensionLength + 4
+	}
+
+	return alpn + "|" + strings.Join(types, "-")
+}
+
+func jarmHash(responses []string) string {
+	empty := true
+	for _, response := range responses {
+		if response != "|||" {
+			empty = false
+		}
+	}
+
+	if empty {
+		return strings.Repeat("0", 62)
+	}
+
+	fuzzyHash := ""
+	alpnsAndExtensions := ""
+	for _, response := range responses {
+		components := strings.Split(response, "|")
+		fuzzyHash += jarmCipherByte(components[0]) + jarmVersionByte(components[1])
+		alpnsAndExtensions += components[2] + components[3]
+	}
+
+	sum := sha256.Sum256([]byte(alpnsAndExtensions))
+	return fuzzyHash + hex.EncodeToString(sum[:])[:32]
+}
+
+// jarmCipherByte returns the 1-based position of cipher in the hash cipher list as two hex digits
+func jarmCipherByte(cipher string) string {
+	if cipher == "" {
+		return "00"
+	}
+
+	count := 1
+	for _, c := range jarmHashCiphers {
+		if hex.EncodeToString([]byte{byte(c >> 8), byte(c)}) == cipher {
+			break
+		}
+		count++
+	}
+
+	hexValue := strconv.FormatInt(int64(count), 16)
+	if len(hexValue) < 2 {
+		hexValue = "0" + hexValue
+	}
+	return hexValue
+}
+
+func jarmVersionByte(version string) string {
+	if len(version) < 4 {
+		return "0"
+	}
+
+	minor, err := strconv.Atoi(version[3:4])
+	if err != nil || minor > 5 {
+		return "0"
+	}
+	return string("abcdef"[minor])
+}
+
+func jarmOrder(values []uint16, order string) []uint16 {
+	indexes := jarmOrderIndexes(len(values), order)
+	ordered := make([]uint16, len(indexes))
+	for i, index := range indexes {
+		ordered[i] = values[index]
+	}
+	return ordered
+}
+
+func jarmOrderStrings(values []string, order string) []string {
+	indexes := jarmOrderIndexes(len(values), order)
+	ordered := make([]string, len(indexes))
+	for i, index := range indexes {
+		ordered[i] = values[index]
+	}
+	return ordered
+}
+
+// jarmOrderIndexes returns the indexes of a list of length items in the order of a JARM probe
+func jarmOrderIndexes(length int, order string) []int {
+	indexes := make([]int, length)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	switch order {
+	case "REVERSE":
+		for i, j := 0, length-1; i < j; i, j = i+1, j-1 {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		}
+		return indexes
+	case "BOTTOM_HALF":
+		return indexes[length/2+length%2:]
+	case "TOP_HALF":
+		var top []int
+		if length%2 == 1 {
+			top = append(top, length/2)
+		}
+		reversed := jarmOrderIndexes(length, "REVERSE")
+		for _, i := range reversed[length/2+length%2:] {
+			top = append(top, i)
+		}
+		return top
+	case "MIDDLE_OUT":
+		middle := length / 2
+		var middleOut []int
+		if length%2 == 1 {
+			middleOut = append(middleOut, middle)
+			for i := 1; i <= middle; i++ {
+				middleOut = append(middleOut, middle+i, middle-i)
+			}
+		} else {
+			for i := 1; i <= middle; i++ {
+				middleOut = append(middleOut, middle-1+i, middle-i)
+			}
+		}
+		return middleOut
+	}
+
+	return indexes
+}
+
+func jarmGREASE() uint16 {
+	value := uint16(mrand.Intn(16))<<4 | 0x0a
+	return value<<8 | value
+}
+
+func appendUint16(data []byte, value uint16, rest ...byte) []byte {
+	data = append(data, byte(value>>8), byte(value))
+	return append(data, rest...)
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// keepAliveIdleConnsPerHost bounds the idle pool of a host without a connection limit
+	keepAliveIdleConnsPerHost = 64
+	keepAliveIdleConnTimeout  = 30 * time.Second
+)
+
+// KeepAlive makes transport reuse connections from a pool per host, opening at most
+// maxConnsPerHost connections to a host at once. zero means no limit
+func KeepAlive(transport *http.Transport, maxConnsPerHost int) {
+	idleConnsPerHost := maxConnsPerHost
+	if idleConnsPerHost <= 0 {
+		idleConnsPerHost = keepAliveIdleConnsPerHost
+	}
+
+	transport.DisableKeepAlives = false
+	transport.MaxConnsPerHost = maxConnsPerHost
+	transport.MaxIdleConnsPerHost = idleConnsPerHost
+	transport.IdleConnTimeout = keepAliveIdleConnTimeout
+}
+
+// KeepAliveTransport returns transport, set up by KeepAlive, sending the requests to an address set
+// by WithConnectAddress over connections closed after them. the pool of a transport is keyed by the
+// url, so a pooled connection of the host may lead to another address
+func KeepAliveTransport(transport *http.Transport) http.RoundTripper {
+	closing := transport.Clone()
+	closing.DisableKeepAlives = true
+	return &keepAliveTransport{transport: transport, closing: closing}
+}
+
+type keepAliveTransport struct {
+	transport *http.Transport
+	closing   *http.Transport
+}
+
+func (k *keepAliveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ConnectAddress(req.Context(), req.URL.Hostname()) != "" {
+		return k.closing.RoundTrip(req)
+	}
+	return k.transport.RoundTrip(req)
+}
+
+func (k *keepAliveTransport) CloseIdleConnections() {
+	k.transport.CloseIdleConnections()
+	k.closing.CloseIdleConnections()
+}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+var ErrInvalidOverride = errors.New("invalid connect override")
+
+type connectAddressesKey struct{}
+
+// ConnectOverride makes connections to Host:Port go to ConnectHost:ConnectPort instead,
+// like curl's --resolve and --connect-to. an empty field matches any host or keeps the port
+type ConnectOverride struct {
+	Host        string
+	Port        string
+	ConnectHost string
+	ConnectPort string
+}
+
+// ParseResolve parses a curl --resolve entry "host:port:address"
+func ParseResolve(entry string) (ConnectOverride, error) {
+	parts := splitOverride(strings.TrimPrefix(strings.TrimSpace(entry), "+"))
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return ConnectOverride{}, ErrInvalidOverride
+	}
+
+	// only the first of several addresses is used
+	address := strings.Split(parts[2], ",")[0]
+	return ConnectOverride{
+		Host:        strings.ToLower(parts[0]),
+		Port:        parts[1],
+		ConnectHost: strings.Trim(address, "[]"),
+	}, nil
+}
+
+// ParseConnectTo parses a curl --connect-to entry "host:port:connect-host:connect-port"
+func ParseConnectTo(entry string) (ConnectOverride, error) {
+	parts := splitOverride(strings.TrimSpace(entry))
+	if len(parts) != 4 || (parts[2] == "" && parts[3] == "") {
+		return ConnectOverride{}, ErrInvalidOverride
+	}
+
+	return ConnectOverride{
+		Host:        strings.ToLower(parts[0]),
+		Port:        parts[1],
+		ConnectHost: parts[2],
+		ConnectPort: parts[3],
+	}, nil
+}
+
+// splitOverride splits an override entry on colons which are not in an ipv6 literal
+func splitOverride(entry string) []string {
+	var parts []string
+	start, inBrackets := 0, false
+	for i, c := range entry {
+		switch {
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case c == ':' && !inBrackets:
+			parts = append(parts, strings.Trim(entry[start:i], "[]"))
+			start = i + 1
+		}
+	}
+
+	return append(parts, strings.Trim(entry[start:], "[]"))
+}
+
+// WithConnectAddress returns a context whose connections to host are made to ip,
+// while the url, Host header and SNI keep using host
+func WithConnectAddress(ctx context.Context, host, ip string) context.Context {
+	addresses := make(map[string]string)
+	if parentAddresses, ok := ctx.Value(connectAddressesKey{}).(map[string]string); ok {
+		for h, a := range parentAddresses {
+			addresses[h] = a
+		}
+	}
+
+	addresses[strings.ToLower(strings.Trim(host, "[]"))] = strings.Trim(ip, "[]")
+	return context.WithValue(ctx, connectAddressesKey{}, addresses)
+}
+
+// ConnectAddress returns the address set by WithConnectAddress for host, or empty
+func ConnectAddress(ctx context.Context, host string) string {
+	addresses, _ := ctx.Value(connectAddressesKey{}).(map[string]string)
+	return addresses[strings.ToLower(strings.Trim(host, "[]"))]
+}
+
+// ConnectAddressDialer dials the address set by WithConnectAddress for the host of addr, or else
+// the address of the first matching override
+func ConnectAddressDialer(dialContext func(ctx context.Context, network, addr string) (net.Conn, error), overrides []ConnectOverride) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialContext = dialerOrDefault(dialContext)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dialContext(ctx, network, addr)
+		}
+		host = strings.ToLower(host)
+
+		if addresses, ok := ctx.Value(connectAddressesKey{}).(map[string]string); ok {
+			if ip, exists := addresses[host]; exists {
+				return dialContext(ctx, network, net.JoinHostPort(ip, port))
+			}
+		}
+
+		for _, override := range overrides {
+			if (override.Host != "" && override.Host != host) || (override.Port != "" && override.Port != port) {
+				continue
+			}
+
+			connectHost, connectPort := override.ConnectHost, override.ConnectPort
+			if connectHost == "" {
+				connectHost = host
+			}
+			if connectPort == "" {
+				connectPort = port
+			}
+			return dialContext(ctx, network, net.JoinHostPort(connectHost, connectPort))
+		}
+
+		return dialContext(ctx, network, addr)
+	}
+}
@@ -48,15 +48,7 @@ func NewResolver() *Resolver  {
 
 func (r *Resolver) DialerWithRandomDNSResolver() func(ctx context.Context, network, addr string) (net.Conn, error) {
 	dialer := &net.Dialer{
-		Resolver: &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				d := net.Dialer{
-				}
-				randomDnsServer := r.DNSServers[rand.Intn(len(r.DNSServers))]
-				return d.DialContext(ctx, "udp", randomDnsServer+":53")
-			},
-		},
+		Resolver: DNSResolver(r.DNSServers),
 	}
 
 	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -68,15 +60,7 @@ func (r *Resolver) DialerWithRandomDNSResolver() func(ctx context.Context, netwo
 
 func (r *Resolver) DialerWithCustomDNSResolver(dnsServers []string) func(ctx context.Context, network, addr string) (net.Conn, error) {
 	dialer := &net.Dialer{
-		Resolver: &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				d := net.Dialer{
-				}
-				randomDnsServer := dnsServers[rand.Intn(len(dnsServers))]
-				return d.DialContext(ctx, "udp", randomDnsServer+":53")
-			},
-		},
+		Resolver: DNSResolver(dnsServers),
 	}
 
 	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -86,38 +70,34 @@ func (r *Resolver) DialerWithCustomDNSResolver(dnsServers []string) func(ctx con
 	return dialContext
 }
 
+// DNSResolver returns a resolver which sends every query to a random server of dnsServers,
+// or the system resolver if no server is given
+func DNSResolver(dnsServers []string) *net.Resolver {
+	if len(dnsServers) == 0 {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{}
+			randomDnsServer := dnsServers[rand.Intn(len(dnsServers))]
+			return d.DialContext(ctx, "udp", randomDnsServer+":53")
+		},
+	}
+}
+
 func (r *Resolver) DefaultTransport(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport {
+	return r.Transport(dialContext, TLSConfig(CompatibleTLS))
+}
+
+// Transport returns a transport dialing with dialContext and using tlsConfig for https assets
+func (r *Resolver) Transport(dialContext func(ctx context.Context, network, addr string) (net.Conn, error), tlsConfig *tls.Config) *http.Transport {
 	transport := &http.Transport{
 		DialContext:         dialContext,
 		MaxIdleConnsPerHost: -1,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-			CipherSuites: []uint16{
-				tls.TLS_RSA_WITH_RC4_128_SHA,
-				tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
-				tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
-				tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
-				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
-			},
-		},
-		DisableKeepAlives: true,
+		TLSClientConfig:     tlsConfig,
+		DisableKeepAlives:   true,
 	}
 	return transport
 }
@@ -0,0 +1,182 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// serviceBannerWait is how long a server-first service is given to send its banner, and
+	// a client-first service to answer a probe
+	serviceBannerWait  = 2 * time.Second
+	serviceBannerSize  = 512
+	serviceBannerLimit = 256
+)
+
+// Service is a non-http service identified on a port
+type Service struct {
+	Name   string
+	Banner string
+}
+
+type serviceBanner struct {
+	name  string
+	match func(banner []byte) bool
+	// skip is the length of the binary header before the text of the banner
+	skip int
+}
+
+type serviceProbe struct {
+	name    string
+	payload []byte
+	match   func(response []byte) bool
+}
+
+// serviceBanners identify services which greet the client first
+var serviceBanners = []serviceBanner{
+	{"ssh", prefix("SSH-"), 0},
+	{"smtp", func(b []byte) bool {
+		return bytes.HasPrefix(b, []byte("220")) && containsAny(b, "SMTP", "Postfix", "Exim", "Sendmail", "mail")
+	}, 0},
+	{"ftp", prefix("220"), 0},
+	{"pop3", prefix("+OK"), 0},
+	{"imap", prefix("* OK"), 0},
+	{"vnc", prefix("RFB "), 0},
+	{"telnet", func(b []byte) bool { return len(b) > 1 && b[0] == 0xff && b[1] >= 0xfb }, 0},
+	// initial handshake packet of protocol version 10 after the 4 byte packet header
+	{"mysql", func(b []byte) bool { return len(b) > 5 && b[4] == 0x0a && b[3] == 0x00 }, 5},
+}
+
+// serviceProbes identify services which wait for the client to talk first
+var serviceProbes = []serviceProbe{
+	{"redis", []byte("PING\r\n"), func(r []byte) bool {
+		return bytes.HasPrefix(r, []byte("+PONG")) || bytes.HasPrefix(r, []byte("-NOAUTH")) || bytes.HasPrefix(r, []byte("-DENIED"))
+	}},
+	{"memcached", []byte("version\r\n"), prefix("VERSION ")},
+	// x.224 connection request with an rdp negotiation request
+	{"rdp", []byte{0x03, 0x00, 0x00, 0x13, 0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00}, func(r []byte) bool {
+		return len(r) > 5 && r[0] == 0x03 && r[1] == 0x00 && r[5] == 0xd0
+	}},
+	// ssl request, answered with a single S or N
+	{"postgresql", []byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f}, func(r []byte) bool {
+		return len(r) == 1 && (r[0] == 'S' || r[0] == 'N')
+	}},
+}
+
+// DetectService identifies the service at addr from its banner or else the response to lightweight probes,
+// sent concurrently. a silent port costs about twice the banner wait. it returns the service and the
+// connected ip, or a nil service if nothing answered
+func DetectService(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), addr string, timeout time.Duration) (*Service, string) {
+	dialContext = dialerOrDefault(dialContext)
+
+	bannerWait := serviceBannerWait
+	if timeout < bannerWait {
+		bannerWait = timeout
+	}
+
+	banner, ip, err := serviceExchange(ctx, dialContext, addr, nil, timeout, bannerWait)
+	if err != nil {
+		return nil, ""
+	}
+
+	if len(banner) > 0 {
+		for _, b := range serviceBanners {
+			if b.match(banner) {
+				return &Service{Name: b.name, Banner: printableBanner(banner[b.skip:])}, ip
+			}
+		}
+		return &Service{Name: "unknown", Banner: printableBanner(banner)}, ip
+	}
+
+	responses := make([][]byte, len(serviceProbes))
+	var wg sync.WaitGroup
+	for i, probe := range serviceProbes {
+		wg.Add(1)
+		go func(i int, probe serviceProbe) {
+			defer wg.Done()
+			if response, _, err := serviceExchange(ctx, dialContext, addr, probe.payload, timeout, bannerWait); err == nil {
+				responses[i] = response
+			}
+		}(i, probe)
+	}
+	wg.Wait()
+
+	for i, probe := range serviceProbes {
+		if len(responses[i]) > 0 && probe.match(responses[i]) {
+			return &Service{Name: probe.name, Banner: printableBanner(responses[i])}, ip
+		}
+	}
+
+	return nil, ""
+}
+
+// serviceExchange connects to addr, writes payload if any and returns what the server sends within wait
+func serviceExchange(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), addr string, payload []byte, timeout, wait time.Duration) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close()
+
+	ip := conn.RemoteAddr().String()
+	if remoteIP, _, err := net.SplitHostPort(ip); err == nil {
+		ip = remoteIP
+	}
+
+	if len(payload) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write(payload); err != nil {
+			return nil, ip, err
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wait))
+	response := make([]byte, serviceBannerSize)
+	n, _ := conn.Read(response)
+	return response[:n], ip, nil
+}
+
+// printableBanner returns the first line of printable text of a banner, up to a null byte
+func printableBanner(banner []byte) string {
+	if i := bytes.IndexByte(banner, 0); i >= 0 {
+		banner = banner[:i]
+	}
+
+	printable := strings.Map(func(r rune) rune {
+		if r == '\n' || (r >= 0x20 && r < 0x7f) {
+			return r
+		}
+		return -1
+	}, string(banner))
+
+	printable = strings.TrimSpace(printable)
+	if i := strings.Index(printable, "\n"); i >= 0 {
+		printable = printable[:i]
+	}
+
+	if len(printable) > serviceBannerLimit {
+		printable = printable[:serviceBannerLimit]
+	}
+	return strings.TrimSpace(printable)
+}
+
+func prefix(p string) func([]byte) bool {
+	return func(b []byte) bool {
+		return bytes.HasPrefix(b, []byte(p))
+	}
+}
+
+func containsAny(b []byte, substrings ...string) bool {
+	for _, s := range substrings {
+		if bytes.Contains(b, []byte(s)) {
+			return true
+		}
+	}
+	return false
+}
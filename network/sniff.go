@@ -0,0 +1,51 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"time"
+
+	"github.com/ghaini/tarantula/constants"
+)
+
+// sniffResponseSize is enough for a tls record header or an http status line
+const sniffResponseSize = 16
+
+// SniffScheme sends a tls ClientHello to addr and tells the scheme from the first bytes of the answer:
+// constants.HTTPS for a tls record, constants.HTTP for an http response to the garbage, constants.TCP for
+// any other service and empty when the server sends nothing. host is sent as SNI
+func SniffScheme(ctx context.Context, dialContext func(ctx context.Context, network, addr string) (net.Conn, error), host, addr string, timeout time.Duration) string {
+	dialContext = dialerOrDefault(dialContext)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", addr)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(jarmProbes[0].clientHello(host)); err != nil {
+		return ""
+	}
+
+	response := make([]byte, sniffResponseSize)
+	n, _ := conn.Read(response)
+	return schemeOfResponse(response[:n])
+}
+
+func schemeOfResponse(response []byte) string {
+	switch {
+	case len(response) == 0:
+		return ""
+	// handshake or alert record of ssl 3.0 to tls 1.3
+	case len(response) >= 3 && (response[0] == 0x16 || response[0] == 0x15) && response[1] == 0x03:
+		return constants.HTTPS
+	case bytes.HasPrefix(response, []byte("HTTP/")):
+		return constants.HTTP
+	}
+
+	return constants.TCP
+}
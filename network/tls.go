@@ -0,0 +1,132 @@
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"time"
+)
+
+type TLSProfile int
+
+const (
+	// CompatibleTLS skips verification and offers TLS 1.0+ with a wide list of cipher suites, the default profile
+	CompatibleTLS TLSProfile = iota
+	// ModernTLS skips verification and offers only TLS 1.2+ with forward secret AEAD suites
+	ModernTLS
+	// LegacyTLS skips verification and offers every version and cipher suite down to TLS 1.0
+	LegacyTLS
+	// StrictTLS verifies certificates and offers only TLS 1.2+ with the go default suites
+	StrictTLS
+)
+
+var compatibleCipherSuites = []uint16{
+	tls.TLS_RSA_WITH_RC4_128_SHA,
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// TLSConfig returns a new tls config of profile
+func TLSConfig(profile TLSProfile) *tls.Config {
+	switch profile {
+	case ModernTLS:
+		return &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS12,
+			CipherSuites:       modernCipherSuites,
+			CurvePreferences:   []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		}
+	case LegacyTLS:
+		var cipherSuites []uint16
+		for _, suite := range tls.CipherSuites() {
+			cipherSuites = append(cipherSuites, suite.ID)
+		}
+		for _, suite := range tls.InsecureCipherSuites() {
+			cipherSuites = append(cipherSuites, suite.ID)
+		}
+
+		return &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS10,
+			CipherSuites:       cipherSuites,
+		}
+	case StrictTLS:
+		return &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		CipherSuites:       compatibleCipherSuites,
+	}
+}
+
+// TLSInfo is the negotiated tls connection and the leaf certificate of an asset
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+	ServerName  string
+	Subject     string
+	Issuer      string
+	DNSNames    []string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	// Fingerprint is the hex sha256 of the leaf certificate
+	Fingerprint string
+}
+
+// TLSInfoOf returns the tls info of a connection state, nil for plain connections
+func TLSInfoOf(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		sum := sha256.Sum256(leaf.Raw)
+		info.Subject = leaf.Subject.String()
+		info.Issuer = leaf.Issuer.String()
+		info.DNSNames = leaf.DNSNames
+		info.NotBefore = leaf.NotBefore
+		info.NotAfter = leaf.NotAfter
+		info.Fingerprint = hex.EncodeToString(sum[:])
+	}
+
+	return info
+}
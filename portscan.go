@@ -0,0 +1,67 @@
+package tarantula
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// portScan forwards the targets whose port accepts tcp connections to inputs and closes inputs once
+// targets is closed, skipping completed and out of scope targets. it runs its own threads, as connects
+// are cheaper than requests
+func (t *tarantula) portScan(targets <-chan input, inputs chan<- input, cp *checkpoint) {
+	dialer := t.connectionDialer()
+	timeout := time.Duration(t.portScanTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Duration(t.timeout) * time.Second
+	}
+
+	threads := t.portScanThread
+	if threads < 1 {
+		threads = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			for inp := range targets {
+				if reason := t.skipReason(inp, cp); reason != "" {
+					t.logger.Debug("skip target", "target", inp.Subdomain, "port", inp.Port, "reason", reason)
+					atomic.AddInt64(&t.stats.skipped, 1)
+					continue
+				}
+
+				if !isPortOpen(dialer, inp.Subdomain, inp.Port, timeout) {
+					// a closed port completes its target
+					t.logger.Debug("skip closed port", "target", inp.Subdomain, "port", inp.Port)
+					t.completeTarget(cp, inp)
+					continue
+				}
+
+				inputs <- inp
+			}
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+	close(inputs)
+}
+
+func isPortOpen(dialContext func(ctx context.Context, network, addr string) (net.Conn, error), host string, port int, timeout time.Duration) bool {
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
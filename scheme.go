@@ -0,0 +1,82 @@
+package tarantula
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghaini/tarantula/constants"
+	"github.com/ghaini/tarantula/network"
+)
+
+// portScheme returns the scheme of port set by SetPortSchemes or known by default, or else the sniffed
+// scheme if the scheme detection is on, sniffed once per address of a scan. it is empty when the scheme
+// may not be changed or is unknown
+func (t *tarantula) portScheme(ctx context.Context, host string, port int, canChangeProtocol bool) string {
+	if !canChangeProtocol {
+		return ""
+	}
+
+	if scheme, ok := t.portSchemes[port]; ok {
+		return scheme
+	}
+
+	if scheme, ok := constants.PortsProtocols[port]; ok {
+		return scheme
+	}
+
+	if !t.sniffScheme {
+		return ""
+	}
+
+	host = strings.Trim(host, "[]")
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if scheme, ok := t.sniffedSchemes.get(address); ok {
+		return scheme
+	}
+
+	scheme := network.SniffScheme(ctx, t.connectionDialer(), host, address, time.Duration(t.timeout)*time.Second)
+	t.sniffedSchemes.set(address, scheme)
+	return scheme
+}
+
+// schemeCache keeps the sniffed schemes of the addresses of a scan, the unknown ones included so
+// retries do not sniff again. a nil cache keeps nothing
+type schemeCache struct {
+	mu      sync.Mutex
+	schemes map[string]string
+}
+
+func newSchemeCache() *schemeCache {
+	return &schemeCache{
+		schemes: make(map[string]string),
+	}
+}
+
+func (c *schemeCache) get(address string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scheme, ok := c.schemes[address]
+	return scheme, ok
+}
+
+func (c *schemeCache) set(address, scheme string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.schemes[address] = scheme
+	c.mu.Unlock()
+}
+
+func isDefaultPort(scheme string, port int) bool {
+	return (scheme == constants.HTTP && port == 80) || (scheme == constants.HTTPS && port == 443)
+}
@@ -0,0 +1,215 @@
+package tarantula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// scopeRegexPrefix marks a scope entry as a regular expression
+	scopeRegexPrefix = "re:"
+	// maxRedirects is the longest redirect chain followed, like the default of net/http
+	maxRedirects = 10
+)
+
+var errRedirectOutOfScope = errors.New("redirect out of scope")
+
+// scope decides which hosts are scanned and which redirects are followed
+type scope struct {
+	include []scopeRule
+	exclude []scopeRule
+}
+
+// scopeRule is one of an apex domain which matches itself and its subdomains, a wildcard
+// pattern like *.dev.example.com, a regular expression or an ip or cidr range
+type scopeRule struct {
+	apex    string
+	pattern string
+	regex   *regexp.Regexp
+	network *net.IPNet
+}
+
+// newScope returns the scope of the include and exclude entries, without the invalid regular expressions
+// whose errors are returned
+func newScope(include, exclude []string) (*scope, []error) {
+	includeRules, includeErrs := parseScopeRules(include)
+	excludeRules, excludeErrs := parseScopeRules(exclude)
+	return &scope{
+		include: includeRules,
+		exclude: excludeRules,
+	}, append(includeErrs, excludeErrs...)
+}
+
+func parseScopeRules(entries []string) ([]scopeRule, []error) {
+	var rules []scopeRule
+	var errs []error
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case strings.HasPrefix(entry, scopeRegexPrefix):
+			regex, err := regexp.Compile(strings.TrimPrefix(entry, scopeRegexPrefix))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("scope entry %q: %w", entry, err))
+				continue
+			}
+			rules = append(rules, scopeRule{regex: regex})
+		case strings.Contains(entry, "*"):
+			rules = append(rules, scopeRule{pattern: normalizeHost(entry)})
+		default:
+			if _, network, err := net.ParseCIDR(entry); err == nil {
+				rules = append(rules, scopeRule{network: network})
+			} else if ip := net.ParseIP(strings.Trim(entry, "[]")); ip != nil {
+				rules = append(rules, scopeRule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}})
+			} else {
+				rules = append(rules, scopeRule{apex: normalizeHost(entry)})
+			}
+		}
+	}
+
+	return rules, errs
+}
+
+func (r scopeRule) match(host string) bool {
+	switch {
+	case r.apex != "":
+		return isSubdomainOf(host, r.apex)
+	case r.pattern != "":
+		matched, _ := path.Match(r.pattern, host)
+		return matched
+	case r.regex != nil:
+		return r.regex.MatchString(host)
+	case r.network != nil:
+		ip := net.ParseIP(host)
+		return ip != nil && r.network.Contains(ip)
+	}
+
+	return false
+}
+
+// inScope reports whether host matches no exclusion and, if there are inclusions, one of them.
+// everything is in the scope of a nil scope
+func (s *scope) inScope(host string) bool {
+	if s == nil {
+		return true
+	}
+
+	host = normalizeHost(host)
+	for _, rule := range s.exclude {
+		if rule.match(host) {
+			return false
+		}
+	}
+
+	if len(s.include) == 0 {
+		return true
+	}
+
+	for _, rule := range s.include {
+		if rule.match(host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// domainOf returns the longest included apex domain of host, the domain of the wildcard
+// patterns included, or empty if host is under none
+func (s *scope) domainOf(host string) string {
+	if s == nil {
+		return ""
+	}
+
+	host = normalizeHost(host)
+	domain := ""
+	for _, rule := range s.include {
+		apex := rule.apex
+		if rule.pattern != "" {
+			apex = strings.TrimLeft(strings.TrimLeft(rule.pattern, "*"), ".")
+			if strings.ContainsAny(apex, "*?[") {
+				continue
+			}
+		}
+
+		if apex != "" && len(apex) > len(domain) && isSubdomainOf(host, apex) {
+			domain = apex
+		}
+	}
+
+	return domain
+}
+
+// targetDomain returns the domain of the results of host, the apex domain of the scope it is under
+// or else domain
+func (t *tarantula) targetDomain(domain, host string) string {
+	if scopeDomain := t.scope.domainOf(host); scopeDomain != "" {
+		return scopeDomain
+	}
+	return domain
+}
+
+// followsRedirect reports whether a redirect to host is followed, the hosts in scope or else the
+// subdomains of domain
+func (t *tarantula) followsRedirect(domain, host string) bool {
+	if t.scope != nil {
+		return t.scope.inScope(host)
+	}
+	return domain == "" || isSubdomainOf(normalizeHost(host), normalizeHost(domain))
+}
+
+type redirectDomainKey struct{}
+
+// withRedirectDomain sets the domain whose subdomains the redirects of the requests of ctx may lead to
+// without a scope
+func withRedirectDomain(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, redirectDomainKey{}, domain)
+}
+
+// checkRedirect stops a redirect chain at the first hop to a host which followsRedirect refuses,
+// other than the host of the first request
+func (t *tarantula) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	host := req.URL.Hostname()
+	if len(via) > 0 && strings.EqualFold(host, via[0].URL.Hostname()) {
+		return nil
+	}
+
+	domain, _ := req.Context().Value(redirectDomainKey{}).(string)
+	if !t.followsRedirect(domain, host) {
+		return errRedirectOutOfScope
+	}
+	return nil
+}
+
+// skipReason returns why inp is not scanned, out of scope or completed by a previous run,
+// or empty if it is scanned
+func (t *tarantula) skipReason(inp input, cp *checkpoint) string {
+	if !t.scope.inScope(inp.Subdomain) {
+		return "out of scope"
+	}
+
+	if cp.isCompleted(inp) {
+		return "completed"
+	}
+	return ""
+}
+
+// isSubdomainOf reports whether host is domain or one of its subdomains
+func isSubdomainOf(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+}
@@ -0,0 +1,43 @@
+package tarantula
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghaini/tarantula/constants"
+	"github.com/ghaini/tarantula/network"
+)
+
+// sendService reports the non-http service listening on port of host, if one answers. only the targets
+// of the asset list are identified, not the redirects and virtual hosts which failed
+func (t *tarantula) sendService(ctx context.Context, domain, host string, port int, result chan<- Result) {
+	if !t.withService || port <= 0 || !isListedTarget(ctx) {
+		return
+	}
+
+	host = strings.Trim(host, "[]")
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	service, ip := network.DetectService(ctx, t.connectionDialer(), address, time.Duration(t.timeout)*time.Second)
+	if service == nil {
+		return
+	}
+
+	if _, exists := t.filterIPsMap[ip]; exists {
+		return
+	}
+
+	if !t.withIP {
+		ip = ""
+	}
+
+	result <- Result{
+		Asset:   constants.TCP + "://" + address,
+		Domain:  domain,
+		IP:      ip,
+		Scheme:  constants.TCP,
+		Service: service,
+	}
+}
@@ -0,0 +1,152 @@
+package tarantula
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// error classes of failed requests
+const (
+	ErrorTimeout = "timeout"
+	ErrorDNS     = "dns"
+	ErrorRefused = "refused"
+	ErrorReset   = "reset"
+	ErrorTLS     = "tls"
+	ErrorOther   = "other"
+)
+
+// scanStats counts the progress of the running scan, safe for concurrent use
+type scanStats struct {
+	queued    int64
+	completed int64
+	skipped   int64
+	requests  int64
+	succeeded int64
+	retries   int64
+	bytesRead int64
+
+	mu     sync.Mutex
+	start  time.Time
+	failed map[string]int64
+}
+
+func newScanStats() *scanStats {
+	return &scanStats{
+		start:  time.Now(),
+		failed: make(map[string]int64),
+	}
+}
+
+// lastScanStats points to the stats of the last started scan of a tarantula
+type lastScanStats struct {
+	mu    sync.Mutex
+	stats *scanStats
+}
+
+func (l *lastScanStats) get() *scanStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+func (l *lastScanStats) set(stats *scanStats) {
+	l.mu.Lock()
+	l.stats = stats
+	l.mu.Unlock()
+}
+
+// addRequest counts a request, as succeeded if it got a response or else as failed by the class of err
+func (s *scanStats) addRequest(err error) {
+	atomic.AddInt64(&s.requests, 1)
+	if err == nil {
+		atomic.AddInt64(&s.succeeded, 1)
+		return
+	}
+
+	class := errorClass(err)
+	s.mu.Lock()
+	s.failed[class]++
+	s.mu.Unlock()
+}
+
+func (s *scanStats) snapshot() Stats {
+	s.mu.Lock()
+	start := s.start
+	failed := make(map[string]int64, len(s.failed))
+	for class, count := range s.failed {
+		failed[class] = count
+	}
+	s.mu.Unlock()
+
+	stats := Stats{
+		TargetsQueued:    atomic.LoadInt64(&s.queued),
+		TargetsCompleted: atomic.LoadInt64(&s.completed),
+		TargetsSkipped:   atomic.LoadInt64(&s.skipped),
+		Requests:         atomic.LoadInt64(&s.requests),
+		Succeeded:        atomic.LoadInt64(&s.succeeded),
+		Failed:           failed,
+		Retries:          atomic.LoadInt64(&s.retries),
+		BytesRead:        atomic.LoadInt64(&s.bytesRead),
+		Elapsed:          time.Since(start),
+	}
+
+	if seconds := stats.Elapsed.Seconds(); seconds > 0 {
+		stats.RequestsPerSecond = float64(stats.Requests) / seconds
+	}
+	return stats
+}
+
+// errorClass returns the class of the error of a failed request
+func errorClass(err error) string {
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRefused
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorReset
+	case errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr):
+		return ErrorTLS
+	}
+
+	return ErrorOther
+}
+
+// Stats returns a snapshot of the progress of the running or last started scan
+func (t *tarantula) Stats() Stats {
+	return t.lastStats.get().snapshot()
+}
+
+// reportProgress calls the progress callback with the stats of the scan every progress interval until
+// done is closed, and once more at the end
+func (t *tarantula) reportProgress(done <-chan struct{}) {
+	ticker := time.NewTicker(t.progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.progressCallback(t.stats.snapshot())
+		case <-done:
+			t.progressCallback(t.stats.snapshot())
+			return
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghaini/tarantula"
+)
+
+// kinds of changes between two scans
+const (
+	AssetAdded               = "asset-added"
+	AssetRemoved             = "asset-removed"
+	StatusChanged            = "status-changed"
+	TitleChanged             = "title-changed"
+	TechnologyAdded          = "technology-added"
+	TechnologyRemoved        = "technology-removed"
+	TechnologyVersionChanged = "technology-version-changed"
+	CertificateChanged       = "certificate-changed"
+)
+
+// Change is a difference of an asset between two scans. Technology is set for the technology
+// changes, Old and New hold the values before and after the change
+type Change struct {
+	Asset      string `json:"asset"`
+	Kind       string `json:"kind"`
+	Technology string `json:"technology,omitempty"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new,omitempty"`
+}
+
+// Diff is the changes from the scan OldScan to NewScan ordered by asset
+type Diff struct {
+	OldScan int64    `json:"old_scan"`
+	NewScan int64    `json:"new_scan"`
+	Changes []Change `json:"changes"`
+}
+
+// Diff compares the results of the scans oldScan and newScan, or returns ErrScanNotFound if one isn't stored
+func (s *Store) Diff(oldScan, newScan int64) (*Diff, error) {
+	for _, scanID := range []int64{oldScan, newScan} {
+		if err := s.checkScan(scanID); err != nil {
+			return nil, err
+		}
+	}
+
+	oldResults, err := s.Results(oldScan)
+	if err != nil {
+		return nil, err
+	}
+
+	newResults, err := s.Results(newScan)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := Compare(oldResults, newResults)
+	diff.OldScan = oldScan
+	diff.NewScan = newScan
+	return diff, nil
+}
+
+// Compare returns the changes from oldResults to newResults matched by asset
+func Compare(oldResults, newResults []tarantula.Result) *Diff {
+	olds := make(map[string]tarantula.Result, len(oldResults))
+	for _, r := range oldResults {
+		olds[r.Asset] = r
+	}
+
+	news := make(map[string]tarantula.Result, len(newResults))
+	for _, r := range newResults {
+		news[r.Asset] = r
+	}
+
+	assets := make([]string, 0, len(olds)+len(news))
+	for asset := range olds {
+		assets = append(assets, asset)
+	}
+	for asset := range news {
+		if _, ok := olds[asset]; !ok {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+
+	diff := &Diff{Changes: []Change{}}
+	for _, asset := range assets {
+		oldResult, inOld := olds[asset]
+		newResult, inNew := news[asset]
+		switch {
+		case !inOld:
+			diff.Changes = append(diff.Changes, Change{Asset: asset, Kind: AssetAdded})
+		case !inNew:
+			diff.Changes = append(diff.Changes, Change{Asset: asset, Kind: AssetRemoved})
+		default:
+			diff.Changes = append(diff.Changes, compareResults(oldResult, newResult)...)
+		}
+	}
+
+	return diff
+}
+
+func compareResults(oldResult, newResult tarantula.Result) []Change {
+	var changes []Change
+	asset := newResult.Asset
+
+	if oldResult.StatusCode != newResult.StatusCode {
+		changes = append(changes, Change{
+			Asset: asset,
+			Kind:  StatusChanged,
+			Old:   strconv.Itoa(oldResult.StatusCode),
+			New:   strconv.Itoa(newResult.StatusCode),
+		})
+	}
+
+	if oldResult.Title != newResult.Title {
+		changes = append(changes, Change{Asset: asset, Kind: TitleChanged, Old: oldResult.Title, New: newResult.Title})
+	}
+
+	oldTechnologies := technologyNames(oldResult)
+	newTechnologies := technologyNames(newResult)
+	for _, name := range oldTechnologies {
+		if !contains(newTechnologies, name) {
+			changes = append(changes, Change{Asset: asset, Kind: TechnologyRemoved, Technology: name, Old: oldResult.Versions[name]})
+		}
+	}
+	for _, name := range newTechnologies {
+		if !contains(oldTechnologies, name) {
+			changes = append(changes, Change{Asset: asset, Kind: TechnologyAdded, Technology: name, New: newResult.Versions[name]})
+		} else if oldResult.Versions[name] != newResult.Versions[name] {
+			changes = append(changes, Change{
+				Asset:      asset,
+				Kind:       TechnologyVersionChanged,
+				Technology: name,
+				Old:        oldResult.Versions[name],
+				New:        newResult.Versions[name],
+			})
+		}
+	}
+
+	oldFingerprint, newFingerprint := certificateFingerprint(oldResult), certificateFingerprint(newResult)
+	if oldFingerprint != newFingerprint {
+		changes = append(changes, Change{Asset: asset, Kind: CertificateChanged, Old: oldFingerprint, New: newFingerprint})
+	}
+
+	return changes
+}
+
+// technologyNames returns the sorted names of the technologies of a result
+func technologyNames(r tarantula.Result) []string {
+	var names []string
+	for _, name := range r.Technologies {
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func certificateFingerprint(r tarantula.Result) string {
+	if r.TLS == nil {
+		return ""
+	}
+	return r.TLS.Fingerprint
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON returns the json encoding of the diff
+func (d *Diff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// String returns the diff in a line per change: + for new assets, - for removed ones and ~ for changes
+func (d *Diff) String() string {
+	var b strings.Builder
+	for _, c := range d.Changes {
+		switch c.Kind {
+		case AssetAdded:
+			fmt.Fprintf(&b, "+ %s\n", c.Asset)
+		case AssetRemoved:
+			fmt.Fprintf(&b, "- %s\n", c.Asset)
+		case StatusChanged:
+			fmt.Fprintf(&b, "~ %s status %s -> %s\n", c.Asset, c.Old, c.New)
+		case TitleChanged:
+			fmt.Fprintf(&b, "~ %s title %q -> %q\n", c.Asset, c.Old, c.New)
+		case TechnologyAdded:
+			fmt.Fprintf(&b, "~ %s technology added %s\n", c.Asset, strings.TrimSpace(c.Technology+" "+c.New))
+		case TechnologyRemoved:
+			fmt.Fprintf(&b, "~ %s technology removed %s\n", c.Asset, strings.TrimSpace(c.Technology+" "+c.Old))
+		case TechnologyVersionChanged:
+			fmt.Fprintf(&b, "~ %s technology %s %s -> %s\n", c.Asset, c.Technology, orNone(c.Old), orNone(c.New))
+		case CertificateChanged:
+			fmt.Fprintf(&b, "~ %s certificate %s -> %s\n", c.Asset, orNone(c.Old), orNone(c.New))
+		}
+	}
+	return b.String()
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// schema creates the tables of a store at version 0, results and their details are keyed by scan id and asset
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	name        TEXT NOT NULL DEFAULT '',
+	started_at  TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS results (
+	scan_id        INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	asset          TEXT NOT NULL,
+	domain         TEXT NOT NULL DEFAULT '',
+	status_code    INTEGER NOT NULL DEFAULT 0,
+	title          TEXT NOT NULL DEFAULT '',
+	body           TEXT NOT NULL DEFAULT '',
+	ip             TEXT NOT NULL DEFAULT '',
+	ipv4           TEXT NOT NULL DEFAULT '[]',
+	ipv6           TEXT NOT NULL DEFAULT '[]',
+	scheme         TEXT NOT NULL DEFAULT '',
+	protocol       TEXT NOT NULL DEFAULT '',
+	ja3            TEXT NOT NULL DEFAULT '',
+	jarm           TEXT NOT NULL DEFAULT '',
+	wildcard       BOOLEAN NOT NULL DEFAULT 0,
+	cdn            TEXT NOT NULL DEFAULT '',
+	dns            TEXT NOT NULL DEFAULT '',
+	takeover       TEXT NOT NULL DEFAULT '',
+	service        TEXT NOT NULL DEFAULT '',
+	banner         TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (scan_id, asset)
+);
+
+CREATE INDEX IF NOT EXISTS results_asset ON results(asset);
+
+CREATE TABLE IF NOT EXISTS headers (
+	scan_id INTEGER NOT NULL,
+	asset   TEXT NOT NULL,
+	name    TEXT NOT NULL,
+	value   TEXT NOT NULL,
+	PRIMARY KEY (scan_id, asset, name),
+	FOREIGN KEY (scan_id, asset) REFERENCES results(scan_id, asset) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS technologies (
+	scan_id  INTEGER NOT NULL,
+	asset    TEXT NOT NULL,
+	category TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	PRIMARY KEY (scan_id, asset, category),
+	FOREIGN KEY (scan_id, asset) REFERENCES results(scan_id, asset) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tls (
+	scan_id      INTEGER NOT NULL,
+	asset        TEXT NOT NULL,
+	version      TEXT NOT NULL,
+	cipher_suite TEXT NOT NULL,
+	server_name  TEXT NOT NULL,
+	subject      TEXT NOT NULL,
+	issuer       TEXT NOT NULL,
+	dns_names    TEXT NOT NULL,
+	not_before   TIMESTAMP,
+	not_after    TIMESTAMP,
+	fingerprint  TEXT NOT NULL,
+	PRIMARY KEY (scan_id, asset),
+	FOREIGN KEY (scan_id, asset) REFERENCES results(scan_id, asset) ON DELETE CASCADE
+);
+`
+
+// migrations upgrade the schema of a store, migrations[i] from version i to i+1. the version of a store
+// is its PRAGMA user_version
+var migrations = []string{
+	`ALTER TABLE technologies ADD COLUMN version TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE results ADD COLUMN body_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE results ADD COLUMN content_length INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE results ADD COLUMN words INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE results ADD COLUMN lines INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE results ADD COLUMN simhash INTEGER NOT NULL DEFAULT 0`,
+}
+
+// migrate creates the tables of db if needed and applies the migrations it misses, each in a transaction
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than the supported %d", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrate schema to version %d: %w", version+1, err)
+		}
+
+		// pragmas take no parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ghaini/tarantula"
+	"github.com/ghaini/tarantula/detector"
+	"github.com/ghaini/tarantula/network"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// DefaultBatchSize is the number of results inserted in one transaction
+	DefaultBatchSize = 500
+	// flushInterval bounds how long a partial batch waits for more results
+	flushInterval = time.Second
+)
+
+var ErrScanNotFound = errors.New("scan not found")
+
+// Store persists the results of scans in a sqlite database
+type Store struct {
+	db        *sql.DB
+	batchSize int
+}
+
+// Scan is a run whose results are stored together
+type Scan struct {
+	ID         int64
+	Name       string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// Open opens or creates the sqlite database at path, and upgrades the schema of a database created
+// by an older version
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{
+		db:        db,
+		batchSize: DefaultBatchSize,
+	}, nil
+}
+
+// SetBatchSize sets the number of results inserted in one transaction
+func (s *Store) SetBatchSize(size int) *Store {
+	if size > 0 {
+		s.batchSize = size
+	}
+	return s
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// NewScan starts a scan and returns its id
+func (s *Store) NewScan(name string) (int64, error) {
+	res, err := s.db.Exec("INSERT INTO scans (name, started_at) VALUES (?, ?)", name, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishScan records the end of a scan
+func (s *Store) FinishScan(scanID int64) error {
+	res, err := s.db.Exec("UPDATE scans SET finished_at = ? WHERE id = ?", time.Now().UTC(), scanID)
+	if err != nil {
+		return err
+	}
+
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		return ErrScanNotFound
+	}
+	return nil
+}
+
+// Save stores the results of a scan received from results, like the channel of GetAssetsChan, in batches
+// until results is closed, and then finishes the scan. results is drained even after an error, which is returned
+func (s *Store) Save(scanID int64, results <-chan tarantula.Result) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var saveErr error
+	batch := make([]tarantula.Result, 0, s.batchSize)
+	flush := func() {
+		if len(batch) > 0 && saveErr == nil {
+			saveErr = s.SaveResults(scanID, batch)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				flush()
+				if saveErr != nil {
+					return saveErr
+				}
+				return s.FinishScan(scanID)
+			}
+
+			batch = append(batch, r)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// SaveResults stores results in one transaction, replacing the results of the same assets in the scan
+func (s *Store) SaveResults(scanID int64, results []tarantula.Result) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteResult, err := tx.Prepare("DELETE FROM results WHERE scan_id = ? AND asset = ?")
+	if err != nil {
+		return err
+	}
+	defer deleteResult.Close()
+
+	insertResult, err := tx.Prepare(`INSERT INTO results (scan_id, asset, domain, status_code, title, body, body_hash,
+		content_length, words, lines, simhash, ip, ipv4, ipv6, scheme, protocol, ja3, jarm, wildcard, cdn, dns, takeover,
+		service, banner) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertResult.Close()
+
+	insertHeader, err := tx.Prepare("INSERT INTO headers (scan_id, asset, name, value) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertHeader.Close()
+
+	insertTechnology, err := tx.Prepare("INSERT INTO technologies (scan_id, asset, category, name, version) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertTechnology.Close()
+
+	insertTLS, err := tx.Prepare(`INSERT INTO tls (scan_id, asset, version, cipher_suite, server_name, subject, issuer,
+		dns_names, not_before, not_after, fingerprint) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertTLS.Close()
+
+	for _, r := range results {
+		if _, err := deleteResult.Exec(scanID, r.Asset); err != nil {
+			return err
+		}
+
+		service, banner := "", ""
+		if r.Service != nil {
+			service, banner = r.Service.Name, r.Service.Banner
+		}
+
+		// sqlite integers are signed, the simhash is stored as its two's complement
+		if _, err := insertResult.Exec(scanID, r.Asset, r.Domain, r.StatusCode, r.Title, r.Body, r.BodyHash,
+			r.ContentLength, r.Words, r.Lines, int64(r.Simhash), r.IP,
+			jsonString(r.IPv4), jsonString(r.IPv6), r.Scheme, r.Protocol, r.JA3, r.JARM, r.Wildcard, r.CDN,
+			jsonString(r.DNS), jsonString(r.Takeover), service, banner); err != nil {
+			return err
+		}
+
+		for name, value := range r.Headers {
+			if _, err := insertHeader.Exec(scanID, r.Asset, name, value); err != nil {
+				return err
+			}
+		}
+
+		for category, name := range r.Technologies {
+			if _, err := insertTechnology.Exec(scanID, r.Asset, category, name, r.Versions[name]); err != nil {
+				return err
+			}
+		}
+
+		if r.TLS != nil {
+			if _, err := insertTLS.Exec(scanID, r.Asset, r.TLS.Version, r.TLS.CipherSuite, r.TLS.ServerName, r.TLS.Subject,
+				r.TLS.Issuer, jsonString(r.TLS.DNSNames), r.TLS.NotBefore, r.TLS.NotAfter, r.TLS.Fingerprint); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Scans returns the stored scans, the latest first
+func (s *Store) Scans() ([]Scan, error) {
+	rows, err := s.db.Query("SELECT id, name, started_at, finished_at FROM scans ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []Scan
+	for rows.Next() {
+		var scan Scan
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&scan.ID, &scan.Name, &scan.StartedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+
+		if finishedAt.Valid {
+			scan.FinishedAt = &finishedAt.Time
+		}
+		scans = append(scans, scan)
+	}
+
+	return scans, rows.Err()
+}
+
+// checkScan returns ErrScanNotFound if no scan has the id scanID
+func (s *Store) checkScan(scanID int64) error {
+	var id int64
+	err := s.db.QueryRow("SELECT id FROM scans WHERE id = ?", scanID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrScanNotFound
+	}
+	return err
+}
+
+// Results returns the results of a scan ordered by asset
+func (s *Store) Results(scanID int64) ([]tarantula.Result, error) {
+	return s.queryResults("scan_id = ?", "asset", scanID)
+}
+
+// History returns the results of asset in every scan, the oldest first
+func (s *Store) History(asset string) ([]tarantula.Result, error) {
+	return s.queryResults("asset = ?", "scan_id", asset)
+}
+
+// resultKey identifies a result and its rows in the detail tables
+type resultKey struct {
+	scanID int64
+	asset  string
+}
+
+// queryResults returns the results matching filter, a condition on scan_id and asset, ordered by order
+func (s *Store) queryResults(filter, order string, args ...interface{}) ([]tarantula.Result, error) {
+	rows, err := s.db.Query(`SELECT scan_id, asset, domain, status_code, title, body, body_hash, content_length, words,
+		lines, simhash, ip, ipv4, ipv6, scheme, protocol, ja3, jarm, wildcard, cdn, dns, takeover, service, banner
+		FROM results WHERE `+filter+` ORDER BY `+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []tarantula.Result
+	index := make(map[resultKey]int)
+	for rows.Next() {
+		var r tarantula.Result
+		var scanID int64
+		var ipv4, ipv6, dns, takeover, service, banner string
+		var simhash int64
+		if err := rows.Scan(&scanID, &r.Asset, &r.Domain, &r.StatusCode, &r.Title, &r.Body, &r.BodyHash,
+			&r.ContentLength, &r.Words, &r.Lines, &simhash, &r.IP, &ipv4, &ipv6,
+			&r.Scheme, &r.Protocol, &r.JA3, &r.JARM, &r.Wildcard, &r.CDN, &dns, &takeover, &service, &banner); err != nil {
+			return nil, err
+		}
+		r.Simhash = uint64(simhash)
+
+		if err := unmarshalColumn("ipv4", r.Asset, ipv4, &r.IPv4); err != nil {
+			return nil, err
+		}
+		if err := unmarshalColumn("ipv6", r.Asset, ipv6, &r.IPv6); err != nil {
+			return nil, err
+		}
+		if dns != "" && dns != "null" {
+			r.DNS = &network.DNSRecord{}
+			if err := unmarshalColumn("dns", r.Asset, dns, r.DNS); err != nil {
+				return nil, err
+			}
+		}
+		if takeover != "" && takeover != "null" {
+			r.Takeover = &detector.TakeoverMatch{}
+			if err := unmarshalColumn("takeover", r.Asset, takeover, r.Takeover); err != nil {
+				return nil, err
+			}
+		}
+		if service != "" {
+			r.Service = &network.Service{Name: service, Banner: banner}
+		}
+
+		r.Headers = make(map[string]string)
+		r.Technologies = make(map[string]string)
+		r.Versions = make(map[string]string)
+		index[resultKey{scanID: scanID, asset: r.Asset}] = len(results)
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := s.loadDetails(filter, args, results, index); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// loadDetails reads the headers, technologies with their versions and tls info of the results matching
+// filter with one query per table, index giving the position of a result in results
+func (s *Store) loadDetails(filter string, args []interface{}, results []tarantula.Result, index map[resultKey]int) error {
+	rows, err := s.db.Query("SELECT scan_id, asset, name, value FROM headers WHERE "+filter, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key resultKey
+		var name, value string
+		if err := rows.Scan(&key.scanID, &key.asset, &name, &value); err != nil {
+			return err
+		}
+		if i, ok := index[key]; ok {
+			results[i].Headers[name] = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = s.db.Query("SELECT scan_id, asset, category, name, version FROM technologies WHERE "+filter, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key resultKey
+		var category, name, version string
+		if err := rows.Scan(&key.scanID, &key.asset, &category, &name, &version); err != nil {
+			return err
+		}
+		if i, ok := index[key]; ok {
+			results[i].Technologies[category] = name
+			if version != "" {
+				results[i].Versions[name] = version
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = s.db.Query(`SELECT scan_id, asset, version, cipher_suite, server_name, subject, issuer, dns_names,
+		not_before, not_after, fingerprint FROM tls WHERE `+filter, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key resultKey
+		var info network.TLSInfo
+		var dnsNames string
+		var notBefore, notAfter sql.NullTime
+		if err := rows.Scan(&key.scanID, &key.asset, &info.Version, &info.CipherSuite, &info.ServerName, &info.Subject,
+			&info.Issuer, &dnsNames, &notBefore, &notAfter, &info.Fingerprint); err != nil {
+			return err
+		}
+
+		if err := unmarshalColumn("dns_names", key.asset, dnsNames, &info.DNSNames); err != nil {
+			return err
+		}
+		info.NotBefore = notBefore.Time
+		info.NotAfter = notAfter.Time
+		if i, ok := index[key]; ok {
+			results[i].TLS = &info
+		}
+	}
+	return rows.Err()
+}
+
+// unmarshalColumn decodes the json of a column of the row of asset into v
+func unmarshalColumn(column, asset, data string, v interface{}) error {
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("decode %s of %s: %w", column, asset, err)
+	}
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ghaini/tarantula"
+	"github.com/ghaini/tarantula/detector"
+	"github.com/ghaini/tarantula/network"
+)
+
+// TestMigrate opens a database created with the schema at version 0 holding a scan, and stores a result
+// using the columns added by the migrations
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO scans (name, started_at) VALUES ('before', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s := openStore(t, path)
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Fatalf("schema version %d, want %d", version, len(migrations))
+	}
+
+	scans, err := s.Scans()
+	if err != nil || len(scans) != 1 || scans[0].Name != "before" {
+		t.Fatalf("scans %+v (%v), want the scan before the migration", scans, err)
+	}
+
+	scanID := saveScan(t, s, tarantula.Result{
+		Asset:        "https://www.example.com",
+		Technologies: map[string]string{"cms": "WordPress"},
+		Versions:     map[string]string{"WordPress": "6.4"},
+	})
+	results, err := s.Results(scanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Versions["WordPress"] != "6.4" {
+		t.Errorf("results %+v, want the version of WordPress", results)
+	}
+
+	// a migrated database opens again without migrating
+	s.Close()
+	openStore(t, path)
+}
+
+// TestRoundTrip saves a result with every stored field and loads it by scan and by asset
+func TestRoundTrip(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "results.db"))
+	result := tarantula.Result{
+		StatusCode:    200,
+		Asset:         "https://www.example.com",
+		Domain:        "example.com",
+		Body:          "<title>Example</title>",
+		BodyHash:      "4f1c0a",
+		ContentLength: 22,
+		Words:         1,
+		Lines:         1,
+		// above the largest signed integer of sqlite
+		Simhash:      1<<63 + 5,
+		IP:           "93.184.216.34",
+		IPv4:         []string{"93.184.216.34"},
+		IPv6:         []string{"2606:2800:220:1::"},
+		JA3:          "771,4865-4866,0-23,29-23,0",
+		JARM:         "29d29d00029d29d00041d41d0000",
+		Protocol:     "HTTP/2.0",
+		Scheme:       "https",
+		Headers:      map[string]string{"server": "ecs"},
+		Technologies: map[string]string{"cms": "WordPress", "web-servers": "Nginx"},
+		Versions:     map[string]string{"WordPress": "6.4"},
+		Title:        "Example",
+		Wildcard:     true,
+		DNS:          &network.DNSRecord{CNAMEs: []string{"www.example.com.cdn.net"}, A: []string{"93.184.216.34"}, Resolver: "1.1.1.1"},
+		CDN:          "Fastly",
+		Takeover:     &detector.TakeoverMatch{Service: "Fastly", Evidence: "Fastly error: unknown domain"},
+		TLS: &network.TLSInfo{
+			Version:     "TLS 1.3",
+			CipherSuite: "TLS_AES_128_GCM_SHA256",
+			ServerName:  "www.example.com",
+			Subject:     "CN=www.example.com",
+			Issuer:      "CN=DigiCert",
+			DNSNames:    []string{"www.example.com", "example.com"},
+			NotBefore:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:    time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+			Fingerprint: "ab12",
+		},
+	}
+	service := tarantula.Result{
+		Asset:        "tcp://www.example.com:6379",
+		Domain:       "example.com",
+		Scheme:       "tcp",
+		Service:      &network.Service{Name: "redis", Banner: "+PONG"},
+		Headers:      map[string]string{},
+		Technologies: map[string]string{},
+		Versions:     map[string]string{},
+	}
+	scanID := saveScan(t, s, result, service)
+
+	results, err := s.Results(scanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []tarantula.Result{result, service}; !reflect.DeepEqual(results, want) {
+		t.Errorf("loaded %+v, want %+v", results, want)
+	}
+
+	history, err := s.History(result.Asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []tarantula.Result{result}; !reflect.DeepEqual(history, want) {
+		t.Errorf("history %+v, want %+v", history, want)
+	}
+}
+
+// TestDiff saves two scans and compares them
+func TestDiff(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "results.db"))
+	oldScan := saveScan(t, s,
+		tarantula.Result{Asset: "https://a.example.com", StatusCode: 200, Title: "A"},
+		tarantula.Result{
+			Asset:        "https://b.example.com",
+			StatusCode:   200,
+			Title:        "Login",
+			Technologies: map[string]string{"cms": "WordPress", "web-servers": "Nginx"},
+			Versions:     map[string]string{"WordPress": "6.3"},
+			TLS:          &network.TLSInfo{DNSNames: []string{"b.example.com"}, Fingerprint: "old"},
+		},
+		tarantula.Result{Asset: "https://c.example.com", StatusCode: 200},
+	)
+	newScan := saveScan(t, s,
+		tarantula.Result{Asset: "https://a.example.com", StatusCode: 200, Title: "A"},
+		tarantula.Result{
+			Asset:        "https://b.example.com",
+			StatusCode:   403,
+			Title:        "Forbidden",
+			Technologies: map[string]string{"cms": "WordPress", "cdn": "Cloudflare"},
+			Versions:     map[string]string{"WordPress": "6.4"},
+			TLS:          &network.TLSInfo{DNSNames: []string{"b.example.com"}, Fingerprint: "new"},
+		},
+		tarantula.Result{Asset: "https://d.example.com", StatusCode: 200},
+	)
+
+	diff, err := s.Diff(oldScan, newScan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Asset: "https://b.example.com", Kind: StatusChanged, Old: "200", New: "403"},
+		{Asset: "https://b.example.com", Kind: TitleChanged, Old: "Login", New: "Forbidden"},
+		{Asset: "https://b.example.com", Kind: TechnologyRemoved, Technology: "Nginx"},
+		{Asset: "https://b.example.com", Kind: TechnologyAdded, Technology: "Cloudflare"},
+		{Asset: "https://b.example.com", Kind: TechnologyVersionChanged, Technology: "WordPress", Old: "6.3", New: "6.4"},
+		{Asset: "https://b.example.com", Kind: CertificateChanged, Old: "old", New: "new"},
+		{Asset: "https://c.example.com", Kind: AssetRemoved},
+		{Asset: "https://d.example.com", Kind: AssetAdded},
+	}
+	if diff.OldScan != oldScan || diff.NewScan != newScan || !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("diff %+v, want the changes %+v", diff, want)
+	}
+}
+
+func TestDiffUnknownScan(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "results.db"))
+	scanID := saveScan(t, s, tarantula.Result{Asset: "https://a.example.com"})
+
+	if _, err := s.Diff(scanID, scanID+1); !errors.Is(err, ErrScanNotFound) {
+		t.Errorf("diff with an unknown new scan returned %v, want ErrScanNotFound", err)
+	}
+	if _, err := s.Diff(scanID+1, scanID); !errors.Is(err, ErrScanNotFound) {
+		t.Errorf("diff with an unknown old scan returned %v, want ErrScanNotFound", err)
+	}
+}
+
+// TestCorruptRow loads a result whose json column is not json
+func TestCorruptRow(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "results.db"))
+	scanID := saveScan(t, s, tarantula.Result{Asset: "https://a.example.com"})
+	if _, err := s.db.Exec("UPDATE results SET ipv4 = '[\"10.0.0.1\"' WHERE scan_id = ?", scanID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Results(scanID); err == nil {
+		t.Error("a corrupt row loads without an error")
+	}
+}
+
+func openStore(t *testing.T, path string) *Store {
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// saveScan stores results as a new scan and returns its id
+func saveScan(t *testing.T, s *Store, results ...tarantula.Result) int64 {
+	scanID, err := s.NewScan("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveResults(scanID, results); err != nil {
+		t.Fatal(err)
+	}
+	return scanID
+}
@@ -1,19 +1,69 @@
 package tarantula
 
+import (
+	"time"
+
+	"github.com/ghaini/tarantula/detector"
+	"github.com/ghaini/tarantula/network"
+)
+
+// Result is a found asset. the body metrics BodyHash, ContentLength, Words, Lines and Simhash are
+// set even without WithBody, Versions holds the detected versions of Technologies by technology name
 type Result struct {
-	StatusCode   int
-	Asset        string
-	Domain       string
-	Body         string
-	IP           string
-	Headers      map[string]string
-	Technologies map[string]string
-	Title        string
+	StatusCode    int
+	Asset         string
+	Domain        string
+	Body          string
+	BodyHash      string
+	ContentLength int
+	Words         int
+	Lines         int
+	Simhash       uint64
+	IP            string
+	IPv4          []string
+	IPv6          []string
+	JA3           string
+	JARM          string
+	Protocol      string
+	Scheme        string
+	TLS           *network.TLSInfo
+	Headers       map[string]string
+	Technologies  map[string]string
+	Versions      map[string]string
+	Title         string
+	Wildcard      bool
+	DNS           *network.DNSRecord
+	CDN           string
+	Takeover      *detector.TakeoverMatch
+	Service       *network.Service
+
+	titleHash string
+}
+
+// Stats is a snapshot of the progress of a scan. Failed counts the failed requests by error class
+type Stats struct {
+	TargetsQueued     int64
+	TargetsCompleted  int64
+	TargetsSkipped    int64
+	Requests          int64
+	Succeeded         int64
+	Failed            map[string]int64
+	Retries           int64
+	BytesRead         int64
+	Elapsed           time.Duration
+	RequestsPerSecond float64
+}
+
+type technologyResult struct {
+	categories map[string]string
+	versions   map[string]string
 }
 
 type input struct {
+	Protocol  string
 	Subdomain string
 	Port      int
+	Path      string
 }
 
 type Technology struct {
@@ -2,15 +2,22 @@ package tarantula
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptrace"
 	u "net/url"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ghaini/tarantula/constants"
@@ -29,30 +36,68 @@ type tarantula struct {
 	withTitle          bool
 	withTechnology     bool
 	withIP             bool
+	withDNS            bool
+	withTakeover       bool
+	withWildcard       bool
+	withJARM           bool
+	filterWildcard     bool
 	userAgents         []string
 	timeout            int
 	retry              int
 	filterStatusCodes  []string
 	filterIPsMap       map[string]struct{}
 	technologyDetector *detector.Technology
+	cdnDetector        *detector.CDN
+	takeoverDetector   *detector.Takeover
 	resolver           *network.Resolver
+	dialer             func(ctx context.Context, network, addr string) (net.Conn, error)
+	proxyDialer        func(ctx context.Context, network, addr string) (net.Conn, error)
+	ipVersion          network.IPVersion
+	connectOverrides   []network.ConnectOverride
+	tlsConfig          *tls.Config
+	clientCertificates []tls.Certificate
+	rootCAs            *x509.CertPool
+	clientHello        network.ClientHello
+	httpVersion        network.HTTPVersion
+	keepAlive          bool
+	sniffScheme        bool
+	withService        bool
+	withPortScan       bool
+	portScanThread     int
+	portScanTimeout    int
+	checkpointPath     string
+	configErrors       []error
+	scope              *scope
+	stats              *scanStats
+	lastStats          *lastScanStats
+	danglingHosts      *hostSet
+	sniffedSchemes     *schemeCache
+	assetIPs           *hostSet
+	metrics            *metrics
+	logger             Logger
+	warc               *WARCWriter
+	progressInterval   time.Duration
+	progressCallback   func(Stats)
+	portSchemes        map[int]string
+	maxConnsPerHost    int
+	dnsServers         []string
+	dnsCache           *network.DNSCache
+	wildcard           *wildcardDetector
+	jarmCache          *jarmCache
 }
 
 func NewTarantula() *tarantula {
 	resolver := network.NewResolver()
 	client := &http.Client{
-		Transport: resolver.DefaultTransport(nil),
 		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
 			return http.ErrUseLastResponse // Tell the http client to not follow redirect
 		},
 	}
 
-	clientWithRedirect := &http.Client{
-		Transport:     resolver.DefaultTransport(nil),
-		CheckRedirect: nil,
-	}
+	// its CheckRedirect keeps the redirect chains in scope, set once t exists
+	clientWithRedirect := &http.Client{}
 	rand.Seed(time.Now().UTC().UnixNano())
-	return &tarantula{
+	t := &tarantula{
 		thread:             1,
 		ports:              []int{443},
 		subdomains:         nil,
@@ -62,7 +107,36 @@ func NewTarantula() *tarantula {
 		timeout:            5,
 		technologyDetector: detector.NewTechnology(),
 		resolver:           resolver,
+		wildcard:           newWildcardDetector(),
+		jarmCache:          newJARMCache(),
+		portSchemes:        make(map[int]string),
+		logger:             nopLogger{},
+		dnsCache:           network.NewDNSCache(),
+		assetIPs:           newHostSet(),
+		tlsConfig:          network.TLSConfig(network.CompatibleTLS),
 	}
+	t.stats = newScanStats()
+	t.lastStats = &lastScanStats{stats: t.stats}
+	t.clientWithRedirect.CheckRedirect = t.checkRedirect
+	t.updateTransport()
+	return t
+}
+
+// newScan returns a copy of t counting the stats of a new scan, so concurrent scans of t keep their own
+func (t *tarantula) newScan() *tarantula {
+	scan := *t
+	scan.stats = newScanStats()
+	scan.danglingHosts = newHostSet()
+	scan.sniffedSchemes = newSchemeCache()
+	t.lastStats.set(scan.stats)
+	for _, err := range t.configErrors {
+		scan.logger.Error("invalid configuration", "error", err)
+	}
+
+	if (t.withDNS || t.withTakeover || t.withWildcard) && len(t.dnsServers) == 0 && len(network.SystemDNSServers()) == 0 {
+		scan.logger.Warn("no dns server in resolv.conf, using the default dns servers", "servers", network.DefaultDNSServers)
+	}
+	return &scan
 }
 
 func (t *tarantula) MultiThread(count int) *tarantula {
@@ -75,6 +149,65 @@ func (t *tarantula) SetPorts(ports []int) *tarantula {
 	return t
 }
 
+// SetPortPreset sets the ports to a named list of data.PortPresets like "top-100-web". an unknown name
+// keeps the ports and is logged as an error when a scan starts
+func (t *tarantula) SetPortPreset(name string) *tarantula {
+	ports, ok := data.PortPresets[name]
+	if !ok {
+		t.configErrors = append(t.configErrors, fmt.Errorf("unknown port preset %q", name))
+		return t
+	}
+	t.ports = ports
+	return t
+}
+
+// SetScope scans only the targets and follows only the redirects in scope: hosts under one of the include
+// entries and none of the exclude entries. an entry is an apex domain matching itself and its subdomains,
+// a wildcard pattern like *.dev.example.com, a regular expression prefixed with "re:" or an ip or cidr.
+// results take the apex domain of their host as domain, so one scan may cover several domains. an invalid
+// regular expression is left out of the scope and logged as an error when a scan starts
+func (t *tarantula) SetScope(include []string, exclude []string) *tarantula {
+	var errs []error
+	t.scope, errs = newScope(include, exclude)
+	t.configErrors = append(t.configErrors, errs...)
+	return t
+}
+
+// SetLogger traces every request attempt, retry, protocol fallback, redirect and dropped response
+// of a scan at debug level to logger. a nil logger logs nothing
+func (t *tarantula) SetLogger(logger Logger) *tarantula {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	t.logger = logger
+	return t
+}
+
+// OnProgress calls callback with the stats of the running scan every interval and once when it ends
+func (t *tarantula) OnProgress(interval time.Duration, callback func(Stats)) *tarantula {
+	t.progressInterval = interval
+	t.progressCallback = callback
+	return t
+}
+
+// Resume records the completed targets of GetAssets in a checkpoint file at path, and skips the ones
+// recorded by a previous run. targets are expanded in a deterministic order, so an interrupted scan
+// resumes where it stopped, including the targets still retrying when it stopped. a checkpoint which
+// can't be read or written is logged as an error, and the scan runs without it
+func (t *tarantula) Resume(path string) *tarantula {
+	t.checkpointPath = path
+	return t
+}
+
+// WithPortScan connects to every port first with its own threads and timeout in milliseconds
+// (zero uses the request timeout), and requests only the open ports
+func (t *tarantula) WithPortScan(threads int, timeoutMillisecond int) *tarantula {
+	t.withPortScan = true
+	t.portScanThread = threads
+	t.portScanTimeout = timeoutMillisecond
+	return t
+}
+
 func (t *tarantula) SetUserAgents(userAgents []string) *tarantula {
 	t.userAgents = userAgents
 	return t
@@ -85,31 +218,245 @@ func (t *tarantula) SetTimeout(second int) *tarantula {
 	return t
 }
 
+// SetRetry retries a failed request number times, before falling back from https to http for targets
+// without a scheme
 func (t *tarantula) SetRetry(number int) *tarantula {
 	t.retry = number
 	return t
 }
 
+// HTTPProxy connects through an http proxy, dns lookups included
 func (t *tarantula) HTTPProxy(proxyAddress string) *tarantula {
-	t.client.Transport = t.resolver.DefaultTransport(network.HTTPProxyDialer(proxyAddress))
+	t.dialer = network.HTTPProxyDialer(proxyAddress)
+	t.setProxyDialer(t.dialer)
+	t.updateTransport()
 	return t
 }
 
+// SocksProxy connects through a socks5 proxy, dns lookups included
 func (t *tarantula) SocksProxy(proxyAddress string) *tarantula {
-	t.client.Transport = t.resolver.DefaultTransport(network.SocksDialer(proxyAddress))
+	t.dialer = network.SocksDialer(proxyAddress)
+	t.setProxyDialer(t.dialer)
+	t.updateTransport()
 	return t
 }
 
 func (t *tarantula) RandomDNSServer() *tarantula {
-	t.client.Transport = t.resolver.DefaultTransport(t.resolver.DialerWithRandomDNSResolver())
+	t.dnsServers = t.resolver.DNSServers
+	t.dialer = t.resolver.DialerWithRandomDNSResolver()
+	t.setProxyDialer(nil)
+	t.updateTransport()
 	return t
 }
 
 func (t *tarantula) SetDNSServer(dnsServers []string) *tarantula {
-	t.client.Transport = t.resolver.DefaultTransport(t.resolver.DialerWithCustomDNSResolver(dnsServers))
+	t.dnsServers = dnsServers
+	t.dialer = t.resolver.DialerWithCustomDNSResolver(dnsServers)
+	t.setProxyDialer(nil)
+	t.updateTransport()
+	return t
+}
+
+// setProxyDialer sends the dns lookups of the scan over tcp through the dialer of a proxy, nil sends them directly
+func (t *tarantula) setProxyDialer(dialer func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	t.proxyDialer = dialer
+	t.dnsCache.SetDialer(dialer)
+}
+
+// ForceIPv4 connects to assets only over ipv4
+func (t *tarantula) ForceIPv4() *tarantula {
+	t.ipVersion = network.IPv4Only
+	t.updateTransport()
 	return t
 }
 
+// ForceIPv6 connects to assets only over ipv6
+func (t *tarantula) ForceIPv6() *tarantula {
+	t.ipVersion = network.IPv6Only
+	t.updateTransport()
+	return t
+}
+
+// PreferIPv4 connects to assets over ipv4, falling back to ipv6
+func (t *tarantula) PreferIPv4() *tarantula {
+	t.ipVersion = network.PreferIPv4
+	t.updateTransport()
+	return t
+}
+
+// PreferIPv6 connects to assets over ipv6, falling back to ipv4
+func (t *tarantula) PreferIPv6() *tarantula {
+	t.ipVersion = network.PreferIPv6
+	t.updateTransport()
+	return t
+}
+
+// Resolve connects to the address of "host:port:address" entries (like curl --resolve) instead of
+// resolving the host, while the url, Host header and SNI keep using the host. an invalid entry is
+// logged as an error when a scan starts
+func (t *tarantula) Resolve(entries []string) *tarantula {
+	for _, entry := range entries {
+		override, err := network.ParseResolve(entry)
+		if err != nil {
+			t.configErrors = append(t.configErrors, fmt.Errorf("resolve %q: %w", entry, err))
+			continue
+		}
+		t.connectOverrides = append(t.connectOverrides, override)
+	}
+
+	t.updateTransport()
+	return t
+}
+
+// ConnectTo connects to connect-host:connect-port of "host:port:connect-host:connect-port" entries
+// (like curl --connect-to), while the url, Host header and SNI keep using the host. an invalid entry
+// is logged as an error when a scan starts
+func (t *tarantula) ConnectTo(entries []string) *tarantula {
+	for _, entry := range entries {
+		override, err := network.ParseConnectTo(entry)
+		if err != nil {
+			t.configErrors = append(t.configErrors, fmt.Errorf("connect to %q: %w", entry, err))
+			continue
+		}
+		t.connectOverrides = append(t.connectOverrides, override)
+	}
+
+	t.updateTransport()
+	return t
+}
+
+// SetTLSProfile uses the tls versions, cipher suites and verification of a profile
+func (t *tarantula) SetTLSProfile(profile network.TLSProfile) *tarantula {
+	t.tlsConfig = network.TLSConfig(profile)
+	t.updateTransport()
+	return t
+}
+
+// SetTLSConfig uses a custom tls config instead of a profile
+func (t *tarantula) SetTLSConfig(config *tls.Config) *tarantula {
+	if config == nil {
+		config = network.TLSConfig(network.CompatibleTLS)
+	}
+	t.tlsConfig = config
+	t.updateTransport()
+	return t
+}
+
+// SetClientCertificates presents certificates to assets requiring mutual tls
+func (t *tarantula) SetClientCertificates(certificates []tls.Certificate) *tarantula {
+	t.clientCertificates = certificates
+	t.updateTransport()
+	return t
+}
+
+// SetRootCAs verifies the certificates of assets against rootCAs, for internal pki
+func (t *tarantula) SetRootCAs(rootCAs *x509.CertPool) *tarantula {
+	t.rootCAs = rootCAs
+	t.updateTransport()
+	return t
+}
+
+// SetClientHello sends the tls ClientHello of a browser, so the JA3 fingerprint matches
+// the user agent. network.UserAgentClientHello picks the browser of each request's user agent
+func (t *tarantula) SetClientHello(hello network.ClientHello) *tarantula {
+	t.clientHello = hello
+	t.updateTransport()
+	return t
+}
+
+// SetHTTPVersion negotiates http/2 over tls (network.AutoHTTPVersion) or forces it (network.HTTP2Only,
+// h2c in cleartext), by default only http/1.1 is spoken
+func (t *tarantula) SetHTTPVersion(version network.HTTPVersion) *tarantula {
+	t.httpVersion = version
+	t.updateTransport()
+	return t
+}
+
+// SetPortSchemes sets the scheme ("http" or "https") of ports, on top of 80 and 443
+func (t *tarantula) SetPortSchemes(schemes map[int]string) *tarantula {
+	for port, scheme := range schemes {
+		t.portSchemes[port] = strings.ToLower(scheme)
+	}
+	return t
+}
+
+// WithSchemeDetection sniffs whether ports without a known scheme speak tls, plain http or
+// another protocol before requesting them, instead of trying https and then http
+func (t *tarantula) WithSchemeDetection() *tarantula {
+	t.sniffScheme = true
+	return t
+}
+
+// WithService identifies non-http services (ssh, ftp, smtp, redis, mysql, rdp, ...) on ports which
+// do not answer http, from their banner or the response to lightweight probes
+func (t *tarantula) WithService() *tarantula {
+	t.withService = true
+	return t
+}
+
+// KeepAlive reuses connections to a host across requests, opening at most maxConnsPerHost
+// connections to each host (zero means no limit). suited to scanning many paths per host
+func (t *tarantula) KeepAlive(maxConnsPerHost int) *tarantula {
+	t.keepAlive = true
+	t.maxConnsPerHost = maxConnsPerHost
+	t.updateTransport()
+	return t
+}
+
+// connectionDialer returns the dialer with the ip version and connect address options applied
+func (t *tarantula) connectionDialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := t.dialer
+	if t.ipVersion != network.AnyIPVersion {
+		dialer = network.IPVersionDialer(dialer, t.ipVersion)
+	}
+	return network.ConnectAddressDialer(dialer, t.connectOverrides)
+}
+
+// updateTransport rebuilds the transports of the clients from the dialer and tls options
+func (t *tarantula) updateTransport() {
+	dialer := t.connectionDialer()
+
+	tlsConfig := t.tlsConfig.Clone()
+	if len(t.clientCertificates) > 0 {
+		tlsConfig.Certificates = t.clientCertificates
+	}
+
+	if t.rootCAs != nil {
+		tlsConfig.RootCAs = t.rootCAs
+		tlsConfig.InsecureSkipVerify = false
+	}
+
+	transport := t.resolver.Transport(dialer, tlsConfig)
+	transportWithRedirect := t.resolver.Transport(dialer, tlsConfig.Clone())
+	network.SetHTTPVersion(transport, t.httpVersion)
+	network.SetHTTPVersion(transportWithRedirect, t.httpVersion)
+	if t.keepAlive {
+		network.KeepAlive(transport, t.maxConnsPerHost)
+		network.KeepAlive(transportWithRedirect, t.maxConnsPerHost)
+	}
+	if t.clientHello != network.GoClientHello {
+		transport.DialTLSContext = network.ClientHelloDialer(dialer, tlsConfig, t.clientHello, t.httpVersion)
+		transportWithRedirect.DialTLSContext = transport.DialTLSContext
+	}
+
+	if t.warc != nil {
+		transport.DisableCompression = true
+		transportWithRedirect.DisableCompression = true
+	}
+
+	var roundTripper, roundTripperWithRedirect http.RoundTripper = transport, transportWithRedirect
+	if t.keepAlive {
+		roundTripper = network.KeepAliveTransport(transport)
+		roundTripperWithRedirect = network.KeepAliveTransport(transportWithRedirect)
+	}
+	if t.warc != nil {
+		roundTripperWithRedirect = &archivingTransport{transport: roundTripperWithRedirect, t: t}
+	}
+
+	t.client.Transport = roundTripper
+	t.clientWithRedirect.Transport = roundTripperWithRedirect
+}
+
 func (t *tarantula) WithBody() *tarantula {
 	t.withBody = true
 	return t
@@ -125,11 +472,41 @@ func (t *tarantula) WithIP() *tarantula {
 	return t
 }
 
+// WithDNS records the CNAME chain, A and AAAA records and the cdn of every asset
+func (t *tarantula) WithDNS() *tarantula {
+	t.withDNS = true
+	if t.cdnDetector == nil {
+		t.cdnDetector = detector.NewCDN()
+	}
+	return t
+}
+
+// WithTakeover detects subdomains pointing to unclaimed services from their cname chain and body
+func (t *tarantula) WithTakeover() *tarantula {
+	t.withTakeover = true
+	if t.takeoverDetector == nil {
+		t.takeoverDetector = detector.NewTakeover()
+	}
+	return t
+}
+
 func (t *tarantula) WithTechnology() *tarantula {
 	t.withTechnology = true
 	return t
 }
 
+// WithWildcard flags results of wildcard domains which respond like a non-existent subdomain
+func (t *tarantula) WithWildcard() *tarantula {
+	t.withWildcard = true
+	return t
+}
+
+// WithJARM records the JARM fingerprint of the tls server of every https asset
+func (t *tarantula) WithJARM() *tarantula {
+	t.withJARM = true
+	return t
+}
+
 func (t *tarantula) FilterStatusCode(codes []string) *tarantula {
 	t.filterStatusCodes = codes
 	return t
@@ -138,120 +515,204 @@ func (t *tarantula) FilterStatusCode(codes []string) *tarantula {
 func (t *tarantula) FilterIPs(ips []string) *tarantula {
 	filterIpsMap := make(map[string]struct{})
 	for _, ip := range ips {
-		filterIpsMap[strings.TrimSpace(ip)] = struct{}{}
+		ip = strings.Trim(strings.TrimSpace(ip), "[]")
+		if parsedIP := net.ParseIP(ip); parsedIP != nil {
+			ip = parsedIP.String()
+		}
+		filterIpsMap[ip] = struct{}{}
 	}
 
 	t.filterIPsMap = filterIpsMap
 	return t
 }
 
+// FilterWildcard drops results of wildcard domains which respond like a non-existent subdomain
+func (t *tarantula) FilterWildcard() *tarantula {
+	t.withWildcard = true
+	t.filterWildcard = true
+	return t
+}
+
 func (t *tarantula) GetAssets(domain string, subdomains []string) []Result {
-	var wg sync.WaitGroup
-	result := make(chan Result, 100)
-	inputs := make(chan input)
 	var results []Result
-	for i := 0; i < t.thread; i++ {
-		wg.Add(1)
-		go func(result chan<- Result, input <-chan input, domain string, work int) {
-			for inp := range inputs {
-				t.doRequest(domain, constants.HTTPS, inp.Subdomain, inp.Port, t.retry, true, result)
-			}
-			wg.Done()
-		}(result, inputs, domain, i)
+	for r := range t.GetAssetsChan(domain, subdomains) {
+		results = append(results, r)
 	}
 
-	go func(subdomains []string) {
+	return results
+}
+
+// GetAssetsChan sends the active assets of targets to the returned channel. a target is a
+// hostname, ip, cidr range, ip range (first-last) or an url whose scheme, port and path are honored
+func (t *tarantula) GetAssetsChan(domain string, subdomains []string) chan Result {
+	targets := make(chan string)
+	go func() {
 		for _, subdomain := range subdomains {
-			for _, port := range t.ports {
-				inputs <- input{
-					Subdomain: subdomain,
-					Port:      port,
-				}
-			}
+			targets <- subdomain
 		}
-		close(inputs)
-	}(subdomains)
+		close(targets)
+	}()
+
+	return t.GetAssetsFromChan(domain, targets)
+}
 
+// GetAssetsFromReader reads targets from r, one or more per line like ReadTargetFile, and sends
+// their active assets to the returned channel. targets are read only as fast as they are scanned.
+// a read error, or a line longer than 1 MiB, ends the input and is logged as an error
+func (t *tarantula) GetAssetsFromReader(domain string, r io.Reader) chan Result {
+	targets := make(chan string)
 	go func() {
-		wg.Wait()
-		close(result)
+		scanner := newTargetScanner(r)
+		for scanner.Scan() {
+			for _, target := range targetsOfLine(scanner.Text()) {
+				targets <- target
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			t.logger.Error("read targets", "error", err)
+		}
+		close(targets)
 	}()
 
-	for r := range result {
-		results = append(results, r)
-	}
-
-	return results
+	return t.GetAssetsFromChan(domain, targets)
 }
 
-func (t *tarantula) GetAssetsChan(domain string, subdomains []string) chan Result {
+// GetAssetsFromChan scans the targets received from subdomains until it is closed, and sends their
+// active assets to the returned channel. a target is received only when a worker is free for it
+func (t *tarantula) GetAssetsFromChan(domain string, subdomains <-chan string) chan Result {
 	var wg sync.WaitGroup
 	result := make(chan Result, 100)
 	inputs := make(chan input)
+
+	t = t.newScan()
+	done := make(chan struct{})
+	if t.progressCallback != nil && t.progressInterval > 0 {
+		go t.reportProgress(done)
+	}
+
+	var cp *checkpoint
+	if t.checkpointPath != "" {
+		// without a usable checkpoint file the scan runs from scratch
+		var err error
+		if cp, err = openCheckpoint(t.checkpointPath); err != nil {
+			t.logger.Error("open checkpoint, scanning from scratch", "path", t.checkpointPath, "error", err)
+		}
+	}
+
 	for i := 0; i < t.thread; i++ {
 		wg.Add(1)
 		go func(result chan<- Result, input <-chan input, domain string, work int) {
+			ctx := withListedTarget(context.Background(), true)
 			for inp := range inputs {
-				t.doRequest(domain, constants.HTTPS, inp.Subdomain, inp.Port, t.retry, true, result)
+				if reason := t.skipReason(inp, cp); reason != "" {
+					t.logger.Debug("skip target", "target", inp.Subdomain, "port", inp.Port, "reason", reason)
+					atomic.AddInt64(&t.stats.skipped, 1)
+					continue
+				}
+
+				t.metrics.workerStarted()
+				targetDomain := t.targetDomain(domain, inp.Subdomain)
+				t.detectWildcard(targetDomain)
+				if inp.Protocol != "" {
+					t.doRequest(ctx, targetDomain, inp.Protocol, inp.Subdomain, inp.Port, inp.Path, t.retry, false, result)
+				} else {
+					t.doRequest(ctx, targetDomain, constants.HTTPS, inp.Subdomain, inp.Port, inp.Path, t.retry, true, result)
+				}
+				t.completeTarget(cp, inp)
+				t.metrics.workerDone()
 			}
 			wg.Done()
 		}(result, inputs, domain, i)
 	}
 
-	go func(subdomains []string) {
-		for _, subdomain := range subdomains {
-			for _, port := range t.ports {
-				inputs <- input{
-					Subdomain: subdomain,
-					Port:      port,
-				}
-			}
+	targets := inputs
+	if t.withPortScan {
+		targets = make(chan input)
+		go t.portScan(targets, inputs, cp)
+	}
+
+	go func() {
+		t.detectWildcard(domain)
+		for subdomain := range subdomains {
+			t.expandTarget(subdomain, targets)
 		}
-		close(inputs)
-	}(subdomains)
+		close(targets)
+	}()
 
 	go func() {
 		wg.Wait()
+		if err := cp.close(); err != nil {
+			t.logger.Error("close checkpoint", "path", t.checkpointPath, "error", err)
+		}
+		close(done)
 		close(result)
 	}()
 	return result
 }
 
-func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry int, canChangeProtocol bool, result chan<- Result) {
+func (t tarantula) doRequest(ctx context.Context, domain, protocol, subdomain string, port int, path string, retry int, canChangeProtocol bool, result chan<- Result) {
+	// retries request the target the way it was asked for, whether or not its protocol may change
+	retryCanChangeProtocol := canChangeProtocol
 	url := subdomain
+	host := network.HostLiteral(subdomain)
 	if protocol != "" {
-		url = protocol + "://" + subdomain
+		url = protocol + "://" + host
 	}
 
 	if port > 0 {
 		url += ":" + strconv.Itoa(port)
-		if schema, ok := constants.PortsProtocols[port]; ok {
-			url = schema + "://" + subdomain
+		if schema := t.portScheme(ctx, subdomain, port, canChangeProtocol); schema != "" {
+			if schema == constants.TCP {
+				// not a web service
+				t.logger.Debug("skip non-http port", "host", subdomain, "port", port)
+				t.sendService(ctx, domain, subdomain, port, result)
+				return
+			}
+
+			url = schema + "://" + host
+			if !isDefaultPort(schema, port) {
+				url += ":" + strconv.Itoa(port)
+			}
 			protocol = schema
 			canChangeProtocol = false
 		}
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	if path != "" {
+		url += path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return
 	}
-	req.Close = true
+	req.Close = !t.keepAlive
 
 	// set headers
-	req.Header.Set("User-Agent", t.userAgents[rand.Intn(len(t.userAgents))])
+	userAgent := t.userAgents[rand.Intn(len(t.userAgents))]
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("ACCEPT", "\ttext/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9")
 	req.Header.Set("accept-language", "en-US,en;q=0.9,ar;q=0.8,es;q=0.7,fa;q=0.6,fr;q=0.5,ja;q=0.4,ms;q=0.3,nl;q=0.2,pt;q=0.1,ru;q=0.1")
 	req.Header.Set("REFERER", url)
 	req.Header.Set("Accept-Charset", "utf-8")
 	req.Header.Set("origin", url)
 
-	var ip string
-	portDetectorRegex := regexp.MustCompile(":.+$")
-	if t.withIP || len(t.filterIPsMap) > 0 {
+	if t.clientHello == network.UserAgentClientHello {
+		req = req.WithContext(network.WithClientHello(req.Context(), network.ClientHelloOfUserAgent(userAgent)))
+	}
+
+	var ip, ja3 string
+	if t.withIP || t.withWildcard || len(t.filterIPsMap) > 0 || t.clientHello != network.GoClientHello || t.warc != nil {
 		trace := &httptrace.ClientTrace{
 			GotConn: func(connInfo httptrace.GotConnInfo) {
-				ip = portDetectorRegex.ReplaceAllString(strings.TrimSpace(connInfo.Conn.RemoteAddr().String()), "")
+				ip = strings.TrimSpace(connInfo.Conn.RemoteAddr().String())
+				if remoteIP, _, err := net.SplitHostPort(ip); err == nil {
+					ip = remoteIP
+				}
+
+				if conn, ok := connInfo.Conn.(*network.JA3Conn); ok {
+					ja3 = conn.JA3()
+				}
 			},
 		}
 
@@ -259,22 +720,39 @@ func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry
 	}
 
 	t.client.Timeout = time.Duration(t.timeout) * time.Second
+	t.logger.Debug("request", "url", url, "retries_left", retry, "can_change_protocol", canChangeProtocol)
+	requestStart := time.Now()
 	resp, err := t.client.Do(req)
-	defer t.client.CloseIdleConnections()
+	t.stats.addRequest(err)
+	t.metrics.observeRequest(req.URL.Scheme, requestStart, statusCodeOf(resp), err)
+	if !t.keepAlive {
+		defer t.client.CloseIdleConnections()
+	}
 	if err != nil {
-		if canChangeProtocol && retry > 0 {
-			t.doRequest(domain, protocol, subdomain, port, retry-1, true, result)
+		if retry > 0 {
+			t.logger.Debug("retry", "url", url, "error", err, "retries_left", retry-1)
+			atomic.AddInt64(&t.stats.retries, 1)
+			t.doRequest(ctx, domain, protocol, subdomain, port, path, retry-1, retryCanChangeProtocol, result)
 			return
 		} else if canChangeProtocol && protocol == constants.HTTPS {
-			t.doRequest(domain, constants.HTTP, subdomain, port, t.retry, true, result)
+			t.logger.Debug("fall back to http", "url", url, "error", err)
+			t.doRequest(ctx, domain, constants.HTTP, subdomain, port, path, t.retry, true, result)
 			return
 		} else {
+			t.logger.Debug("request failed", "url", url, "error", err)
+			t.sendService(ctx, domain, subdomain, port, result)
+			t.sendDanglingTakeover(ctx, domain, url, req.URL.Hostname(), result)
 			return
 		}
 	}
 
 	bodyResponse := resp.Body
 	bodyBytes, readErr := ioutil.ReadAll(bodyResponse)
+	atomic.AddInt64(&t.stats.bytesRead, int64(len(bodyBytes)))
+	if readErr != nil {
+		t.metrics.readError()
+	}
+	t.archive(req, resp, bodyBytes, ip, requestStart)
 	headerResponse := resp.Header
 	cookieResponse := resp.Cookies()
 	ResponseUrl := resp.Request.URL.String()
@@ -282,15 +760,18 @@ func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry
 	statusCodeStr := strconv.Itoa(resp.StatusCode)
 	for _, sc := range t.filterStatusCodes {
 		if strings.HasSuffix(sc, "xx") && sc[0] == statusCodeStr[0] && !strings.HasSuffix(statusCodeStr, "00") {
+			t.logger.Debug("drop response", "url", url, "reason", "status code filter", "status", statusCode)
 			return
 		}
 
 		if sc == statusCodeStr {
+			t.logger.Debug("drop response", "url", url, "reason", "status code filter", "status", statusCode)
 			return
 		}
 	}
 
 	if _, exists := t.filterIPsMap[ip]; exists {
+		t.logger.Debug("drop response", "url", url, "reason", "ip filter", "ip", ip)
 		return
 	}
 
@@ -299,23 +780,32 @@ func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry
 
 	var responseWithRedirect *http.Response
 	if err == nil {
-		match, _ := regexp.MatchString("https?://"+subdomain, redirectedLocation.String())
-		if match {
-			redirectedUrl, _ := u.Parse(redirectedLocation.String())
-			if redirectedUrl.RequestURI() == "/" {
+		isHTTP := redirectedLocation.Scheme == constants.HTTP || redirectedLocation.Scheme == constants.HTTPS
+		if isHTTP && strings.EqualFold(redirectedLocation.Hostname(), strings.Trim(host, "[]")) {
+			if redirectedLocation.RequestURI() == "/" {
+				t.logger.Debug("drop response", "url", url, "reason", "redirect to the root of the same host", "location", redirectedLocation.String())
 				return
 			}
+			t.logger.Debug("follow same-host redirect", "url", url, "location", redirectedLocation.String())
 			t.clientWithRedirect.Timeout = time.Duration(t.timeout) * time.Second
-			responseWithRedirect, err = t.clientWithRedirect.Do(req)
+			requestStart = time.Now()
+			responseWithRedirect, err = t.clientWithRedirect.Do(req.WithContext(withRedirectDomain(req.Context(), domain)))
+			t.stats.addRequest(err)
+			t.metrics.observeRequest(req.URL.Scheme, requestStart, statusCodeOf(responseWithRedirect), err)
 			if err == nil {
 				defer responseWithRedirect.Body.Close()
+			} else if errors.Is(err, errRedirectOutOfScope) {
+				t.logger.Debug("skip redirect", "url", url, "location", redirectedLocation.String(), "reason", "chain leaves the scope")
 			}
 		} else {
 			redirectedLocationUrl := detector.ConvertToUrlWithPort(redirectedLocation)
-			if strings.Contains(redirectedLocationUrl, domain) {
+			if t.followsRedirect(domain, redirectedLocation.Hostname()) {
+				t.logger.Debug("follow redirect", "url", url, "location", redirectedLocation.String())
 				parsedRedirectedLocationUrl, _ := u.Parse(redirectedLocationUrl)
 				redirectedLocationUrlPort, _ := strconv.Atoi(parsedRedirectedLocationUrl.Port())
-				t.doRequest(domain, parsedRedirectedLocationUrl.Scheme, parsedRedirectedLocationUrl.Hostname(), redirectedLocationUrlPort, 0, false, result)
+				t.doRequest(withListedTarget(ctx, false), domain, parsedRedirectedLocationUrl.Scheme, parsedRedirectedLocationUrl.Hostname(), redirectedLocationUrlPort, "", 0, false, result)
+			} else {
+				t.logger.Debug("skip redirect", "url", url, "location", redirectedLocation.String(), "reason", "out of scope")
 			}
 		}
 	}
@@ -327,10 +817,19 @@ func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry
 
 	body := ""
 	title := ""
+	titleHash := ""
+	bodyHash := ""
+	contentLength, words, lines := 0, 0, 0
+	var simhash uint64
 	technologies := make(map[string]string)
+	technologyVersions := make(map[string]string)
 	if responseWithRedirect != nil {
 		bodyResponse = responseWithRedirect.Body
 		bodyBytes, readErr = ioutil.ReadAll(bodyResponse)
+		atomic.AddInt64(&t.stats.bytesRead, int64(len(bodyBytes)))
+		if readErr != nil {
+			t.metrics.readError()
+		}
 		headerResponse = responseWithRedirect.Header
 		cookieResponse = responseWithRedirect.Cookies()
 		ResponseUrl = responseWithRedirect.Request.URL.String()
@@ -344,14 +843,15 @@ func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry
 		if t.withTechnology {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
 			defer cancel()
-			technology := make(chan map[string]string, 1)
+			technology := make(chan technologyResult, 1)
 
 			go func(url string, body []byte, headers http.Header, cookies []*http.Cookie) {
 				technology <- t.getTechnologyMap(url, body, headers, cookies)
 			}(ResponseUrl, bodyBytes, headerResponse, cookieResponse)
 
 			select {
-			case technologies = <-technology:
+			case detected := <-technology:
+				technologies, technologyVersions = detected.categories, detected.versions
 			case <-ctx.Done():
 			}
 		}
@@ -359,39 +859,248 @@ func (t tarantula) doRequest(domain, protocol, subdomain string, port int, retry
 		if t.withBody {
 			body = string(bodyBytes)
 		}
+
+		// the title hash is compared by the wildcard and virtual host detection
+		fingerprintTitle := title
+		if !t.withTitle {
+			fingerprintTitle = detector.ExtractTitle(bodyBytes, headerResponse)
+		}
+		titleHash = hashTitle(fingerprintTitle)
+		bodyHash = detector.BodyHash(bodyBytes)
+		contentLength = len(bodyBytes)
+		words = detector.CountWords(bodyBytes)
+		lines = detector.CountLines(bodyBytes)
+		simhash = detector.Simhash(bodyBytes)
 	}
 
-	asset := url
-	parsedUrl, err := u.Parse(url)
-	if err == nil {
-		asset = detector.ConvertToUrlWithPort(parsedUrl)
+	wildcard := t.withWildcard && t.wildcard.isWildcard(domain, ip, pageResponse{
+		statusCode: statusCode,
+		length:     contentLength,
+		titleHash:  titleHash,
+		simhash:    simhash,
+	})
+	if wildcard && t.filterWildcard {
+		t.logger.Debug("drop response", "url", url, "reason", "wildcard")
+		return
+	}
+
+	asset := assetFromUrl(url)
+
+	var dnsRecord *network.DNSRecord
+	cdn := ""
+	if t.withDNS || t.withTakeover || t.withIP {
+		dnsRecord = t.lookupDNS(req.URL.Hostname())
+	}
+
+	var ipv4, ipv6 []string
+	if t.withIP && dnsRecord != nil {
+		ipv4 = dnsRecord.A
+		ipv6 = dnsRecord.AAAA
+	} else if t.withIP && ip != "" {
+		if parsedIP := net.ParseIP(ip); parsedIP != nil && parsedIP.To4() != nil {
+			ipv4 = []string{ip}
+		} else if parsedIP != nil {
+			ipv6 = []string{ip}
+		}
+	}
+
+	if t.withDNS && dnsRecord != nil {
+		cdn = t.cdnDetector.Detect(dnsRecord.CNAMEs, append(dnsRecord.IPs(), ip))
+	}
+
+	var takeover *detector.TakeoverMatch
+	if t.withTakeover && dnsRecord != nil && readErr == nil {
+		takeover = t.takeoverDetector.Detect(dnsRecord.CNAMEs, bodyBytes)
+	}
+
+	jarm := ""
+	if t.withJARM && req.URL.Scheme == constants.HTTPS {
+		jarm = t.jarm(ctx, req.URL.Hostname(), req.URL.Port())
+	}
+
+	if !t.withDNS {
+		// looked up only for the takeover detection or ip families
+		dnsRecord = nil
+	}
+
+	if t.withIP && ip != "" {
+		t.assetIPs.add(ip)
 	}
 
 	result <- Result{
-		StatusCode:   statusCode,
-		Asset:        asset,
-		Domain:       domain,
-		Body:         body,
-		Headers:      headers,
-		Title:        title,
-		IP:           ip,
-		Technologies: technologies,
+		StatusCode:    statusCode,
+		Asset:         asset,
+		Domain:        domain,
+		Body:          body,
+		BodyHash:      bodyHash,
+		ContentLength: contentLength,
+		Words:         words,
+		Lines:         lines,
+		Simhash:       simhash,
+		Headers:       headers,
+		Title:         title,
+		IP:            ip,
+		IPv4:          ipv4,
+		IPv6:          ipv6,
+		JA3:           ja3,
+		JARM:          jarm,
+		Protocol:      resp.Proto,
+		TLS:           network.TLSInfoOf(resp.TLS),
+		Scheme:        req.URL.Scheme,
+		Technologies:  technologies,
+		Versions:      technologyVersions,
+		Wildcard:      wildcard,
+		DNS:           dnsRecord,
+		CDN:           cdn,
+		Takeover:      takeover,
+		titleHash:     titleHash,
 	}
 }
 
-func (t *tarantula) getTechnologyMap(url string, body []byte, headers http.Header, cookies []*http.Cookie) map[string]string {
-	technologies := make(map[string]string)
+// lookupDNS returns the dns record of host, or nil if host is an ip or the lookup fails
+func (t *tarantula) lookupDNS(host string) *network.DNSRecord {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.timeout)*time.Second)
+	defer cancel()
+	dnsRecord, err := t.dnsCache.Lookup(ctx, host, t.dnsServers)
+	if err != nil {
+		return nil
+	}
+
+	return dnsRecord
+}
+
+// sendDanglingTakeover reports an unreachable target of the asset list whose cname chain points to
+// a service which is vulnerable when the target does not resolve, once per host of a scan
+func (t *tarantula) sendDanglingTakeover(ctx context.Context, domain, url, host string, result chan<- Result) {
+	if !t.withTakeover || !isListedTarget(ctx) || !t.danglingHosts.add(host) {
+		return
+	}
+
+	dnsRecord := t.lookupDNS(host)
+	if dnsRecord == nil || len(dnsRecord.CNAMEs) == 0 || len(dnsRecord.IPs()) > 0 {
+		return
+	}
+
+	takeover := t.takeoverDetector.DetectDangling(dnsRecord.CNAMEs)
+	if takeover == nil {
+		return
+	}
+
+	asset := assetFromUrl(url)
+
+	var dns *network.DNSRecord
+	if t.withDNS {
+		dns = dnsRecord
+	}
+
+	result <- Result{
+		Asset:    asset,
+		Domain:   domain,
+		DNS:      dns,
+		Takeover: takeover,
+	}
+}
+
+type listedTargetKey struct{}
+
+// withListedTarget marks whether the requests of ctx are for a target of the asset list, unlike the
+// requests of redirects and virtual hosts. only listed targets report what an unreachable target is
+func withListedTarget(ctx context.Context, listed bool) context.Context {
+	return context.WithValue(ctx, listedTargetKey{}, listed)
+}
+
+func isListedTarget(ctx context.Context) bool {
+	listed, _ := ctx.Value(listedTargetKey{}).(bool)
+	return listed
+}
+
+// hostSet is a set of hosts safe for concurrent use
+type hostSet struct {
+	mu    sync.Mutex
+	hosts map[string]struct{}
+}
+
+func newHostSet() *hostSet {
+	return &hostSet{
+		hosts: make(map[string]struct{}),
+	}
+}
+
+// list returns the sorted hosts of the set
+func (s *hostSet) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hosts := make([]string, 0, len(s.hosts))
+	for host := range s.hosts {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+	return hosts
+}
+
+// add adds host to the set and reports whether it was not in it yet
+func (s *hostSet) add(host string) bool {
+	if s == nil {
+		return true
+	}
+
+	host = strings.ToLower(host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.hosts[host]; exists {
+		return false
+	}
+	s.hosts[host] = struct{}{}
+	return true
+}
+
+// assetFromUrl returns the url with its port, keeping the path of url targets
+func assetFromUrl(url string) string {
+	parsedUrl, err := u.Parse(url)
+	if err != nil {
+		return url
+	}
+
+	asset := detector.ConvertToUrlWithPort(parsedUrl)
+	if parsedUrl.Path != "" && parsedUrl.Path != "/" {
+		asset += parsedUrl.RequestURI()
+	}
+	return asset
+}
+
+func (t *tarantula) getTechnologyMap(url string, body []byte, headers http.Header, cookies []*http.Cookie) technologyResult {
+	defer t.metrics.observeTechnology(time.Now())
+	technologies := technologyResult{
+		categories: make(map[string]string),
+		versions:   make(map[string]string),
+	}
 	matches := t.technologyDetector.Technology(url, body, headers, cookies)
 	for _, match := range matches {
+		name := strings.ToLower(match.AppName)
 		for _, cat := range match.CatNames {
 			cat = strings.ToLower(cat)
 			cat = strings.ReplaceAll(cat, " ", "-")
-			technologies[cat] = strings.ToLower(match.AppName)
+			technologies.categories[cat] = name
+		}
+		if match.Version != "" {
+			technologies.versions[name] = match.Version
 		}
 	}
 	return technologies
 }
 
+func statusCodeOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
 func (t *tarantula) GetAssetStatusCode(asset string, retryCount int) int {
 	var wg sync.WaitGroup
 	result := make(chan Result)
@@ -399,7 +1108,7 @@ func (t *tarantula) GetAssetStatusCode(asset string, retryCount int) int {
 	wg.Add(retryCount)
 	for i := 0; i < retryCount; i++ {
 		go func() {
-			t.doRequest("", "", asset, 0, 0, false, result)
+			t.doRequest(context.Background(), "", "", asset, 0, "", 0, false, result)
 			wg.Done()
 		}()
 	}
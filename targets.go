@@ -0,0 +1,194 @@
+package tarantula
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	u "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ghaini/tarantula/constants"
+)
+
+// maxTargetLineSize is the longest line of targets read, long enough for a comma separated list
+const maxTargetLineSize = 1024 * 1024
+
+// expandTarget sends the inputs of a target to inputs. hostnames and ips are tried on every port,
+// cidr and ip ranges are expanded one address at a time and urls keep their own scheme, port and path
+func (t *tarantula) expandTarget(target string, inputs chan<- input) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return
+	}
+
+	if strings.Contains(target, "://") {
+		if inp, ok := urlInput(target); ok {
+			t.queueInput(inputs, inp)
+		}
+		return
+	}
+
+	if _, network, err := net.ParseCIDR(target); err == nil {
+		first := network.IP.Mask(network.Mask)
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^network.Mask[i]
+		}
+		t.expandIPRange(first, last, inputs)
+		return
+	}
+
+	if first, last, ok := parseIPRange(target); ok {
+		t.expandIPRange(first, last, inputs)
+		return
+	}
+
+	for _, port := range t.ports {
+		t.queueInput(inputs, input{
+			Subdomain: target,
+			Port:      port,
+		})
+	}
+}
+
+func (t *tarantula) expandIPRange(first, last net.IP, inputs chan<- input) {
+	for ip := first; bytes.Compare(ip, last) <= 0; ip = nextIP(ip) {
+		for _, port := range t.ports {
+			t.queueInput(inputs, input{
+				Subdomain: ip.String(),
+				Port:      port,
+			})
+		}
+
+		if ip.Equal(last) {
+			return
+		}
+	}
+}
+
+// queueInput counts inp as queued and sends it to inputs, waiting for a free worker
+func (t *tarantula) queueInput(inputs chan<- input, inp input) {
+	atomic.AddInt64(&t.stats.queued, 1)
+	inputs <- inp
+}
+
+// urlInput keeps the scheme, port and path of an url target, the port defaults to the scheme port
+func urlInput(target string) (input, bool) {
+	parsedUrl, err := u.Parse(target)
+	if err != nil || parsedUrl.Hostname() == "" {
+		return input{}, false
+	}
+
+	scheme := strings.ToLower(parsedUrl.Scheme)
+	if scheme != constants.HTTP && scheme != constants.HTTPS {
+		return input{}, false
+	}
+
+	port, err := strconv.Atoi(parsedUrl.Port())
+	if err != nil {
+		port = 80
+		if scheme == constants.HTTPS {
+			port = 443
+		}
+	}
+
+	path := parsedUrl.RequestURI()
+	if path == "/" {
+		path = ""
+	}
+
+	return input{
+		Protocol:  scheme,
+		Subdomain: parsedUrl.Hostname(),
+		Port:      port,
+		Path:      path,
+	}, true
+}
+
+// parseIPRange parses a first-last ip range of the same ip version
+func parseIPRange(target string) (net.IP, net.IP, bool) {
+	parts := strings.SplitN(target, "-", 2)
+	if len(parts) != 2 {
+		return nil, nil, false
+	}
+
+	first := net.ParseIP(strings.TrimSpace(parts[0]))
+	last := net.ParseIP(strings.TrimSpace(parts[1]))
+	if first == nil || last == nil {
+		return nil, nil, false
+	}
+
+	if first4, last4 := first.To4(), last.To4(); first4 != nil && last4 != nil {
+		return first4, last4, bytes.Compare(first4, last4) <= 0
+	} else if first4 != nil || last4 != nil {
+		return nil, nil, false
+	}
+
+	return first, last, bytes.Compare(first, last) <= 0
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// ReadTargetFile reads the targets of a file, one per line. lines may be prefixed with an
+// as number like "AS13335 104.16.0.0/13", and everything after a # is a comment
+func ReadTargetFile(path string) ([]string, error) {
+	targetsFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer targetsFile.Close()
+
+	var targets []string
+	scanner := newTargetScanner(targetsFile)
+	for scanner.Scan() {
+		targets = append(targets, targetsOfLine(scanner.Text())...)
+	}
+
+	return targets, scanner.Err()
+}
+
+// newTargetScanner returns a scanner of the lines of r, up to maxTargetLineSize long
+func newTargetScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxTargetLineSize)
+	return scanner
+}
+
+// targetsOfLine returns the targets of a line separated by spaces, tabs or commas, without
+// as numbers and comments
+func targetsOfLine(line string) []string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+
+	var targets []string
+	for _, field := range strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	}) {
+		if strings.HasPrefix(strings.ToUpper(field), "AS") && isNumber(field[2:]) {
+			continue
+		}
+		targets = append(targets, field)
+	}
+
+	return targets
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return s != "" && err == nil
+}
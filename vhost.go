@@ -0,0 +1,121 @@
+package tarantula
+
+import (
+	"context"
+	u "net/url"
+	"strings"
+	"sync"
+
+	"github.com/ghaini/tarantula/constants"
+	"github.com/ghaini/tarantula/network"
+)
+
+type vhostInput struct {
+	ip       string
+	host     string
+	port     int
+	baseline []Result
+}
+
+func (t *tarantula) GetVirtualHosts(domain string, ips []string, hosts []string) []Result {
+	var results []Result
+	for r := range t.GetVirtualHostsChan(domain, ips, hosts) {
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// GetVirtualHostsChan connects to every ip on every port with each of hosts as the Host header and SNI,
+// and sends the hosts whose response differs from the response to a non-existent host. without ips it
+// connects to the ips of the assets found by the previous scans WithIP. an ip and port whose non-existent
+// host gets no response, even after the retries, is skipped as there is nothing to compare to
+func (t *tarantula) GetVirtualHostsChan(domain string, ips []string, hosts []string) chan Result {
+	if len(ips) == 0 {
+		ips = t.assetIPs.list()
+	}
+
+	var wg sync.WaitGroup
+	result := make(chan Result, 100)
+	inputs := make(chan vhostInput)
+	for i := 0; i < t.thread; i++ {
+		wg.Add(1)
+		go func() {
+			for inp := range inputs {
+				t.doVirtualHostRequest(domain, inp, result)
+			}
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		for _, ip := range ips {
+			for _, port := range t.ports {
+				baseline := t.virtualHostResponses(domain, ip, randomLabel(wildcardLabelLength)+"."+domain, port)
+				if len(baseline) == 0 {
+					t.logger.Debug("skip virtual hosts", "ip", ip, "port", port, "reason", "no baseline response")
+					continue
+				}
+
+				for _, host := range hosts {
+					inputs <- vhostInput{
+						ip:       ip,
+						host:     strings.TrimSpace(host),
+						port:     port,
+						baseline: baseline,
+					}
+				}
+			}
+		}
+		close(inputs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(result)
+	}()
+	return result
+}
+
+func (t *tarantula) doVirtualHostRequest(domain string, inp vhostInput, result chan<- Result) {
+	for _, r := range t.virtualHostResponses(domain, inp.ip, inp.host, inp.port) {
+		if isSameResponse(r, inp.baseline) {
+			continue
+		}
+
+		r.IP = inp.ip
+		result <- r
+	}
+}
+
+// virtualHostResponses requests host on port while connecting to ip, ignoring redirects to other hosts
+func (t *tarantula) virtualHostResponses(domain, ip, host string, port int) []Result {
+	ctx := network.WithConnectAddress(context.Background(), host, ip)
+	responses := make(chan Result)
+	go func() {
+		t.doRequest(ctx, domain, constants.HTTPS, host, port, "", t.retry, true, responses)
+		close(responses)
+	}()
+
+	var results []Result
+	for r := range responses {
+		parsedAsset, err := u.Parse(r.Asset)
+		if err != nil || !strings.EqualFold(parsedAsset.Hostname(), strings.Trim(host, "[]")) {
+			continue
+		}
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// isSameResponse reports whether r has the status, title and about the length of one of baseline
+func isSameResponse(r Result, baseline []Result) bool {
+	for _, b := range baseline {
+		if r.StatusCode == b.StatusCode && r.titleHash == b.titleHash && similarLength(r.ContentLength, b.ContentLength) {
+			return true
+		}
+	}
+
+	return false
+}
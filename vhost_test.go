@@ -0,0 +1,59 @@
+package tarantula
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestVirtualHostsKeepAlive finds a virtual host served differently by two ips on the same port, with
+// and without KeepAlive, so a pooled connection to one ip must not answer the requests of the other
+func TestVirtualHostsKeepAlive(t *testing.T) {
+	first := startVirtualHostServer(t, "127.0.0.1:0", "A")
+	port := first.Listener.Addr().(*net.TCPAddr).Port
+	startVirtualHostServer(t, net.JoinHostPort("127.0.0.2", strconv.Itoa(port)), "B")
+
+	for _, keepAlive := range []bool{false, true} {
+		scanner := NewTarantula().SetPorts([]int{port}).WithTitle().MultiThread(4)
+		if keepAlive {
+			scanner.KeepAlive(4)
+		}
+
+		var found []string
+		for _, r := range scanner.GetVirtualHosts("test", []string{"127.0.0.1", "127.0.0.2"}, []string{"secret.test", "www.test"}) {
+			found = append(found, r.IP+" "+r.Title)
+		}
+		sort.Strings(found)
+
+		want := []string{"127.0.0.1 secret on A", "127.0.0.2 secret on B"}
+		if strings.Join(found, ",") != strings.Join(want, ",") {
+			t.Errorf("keep-alive %v found %q, want %q", keepAlive, found, want)
+		}
+	}
+}
+
+// startVirtualHostServer serves secret.test with a page naming server on address, and a default page
+// to any other host
+func startVirtualHostServer(t *testing.T, address, server string) *httptest.Server {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("listen on %s: %v", address, err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Host, "secret.test") {
+			w.Write([]byte("<title>secret on " + server + "</title>"))
+			return
+		}
+		w.Write([]byte("<title>default</title>"))
+	}))
+	srv.Listener.Close()
+	srv.Listener = listener
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -0,0 +1,276 @@
+package tarantula
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const warcVersion = "WARC/1.1"
+
+// WARCWriter archives the http exchanges of scans in WARC files: a request record with the headers
+// tarantula sent and a response record with the status line, headers and body received. bodies are
+// recorded after the transfer decoding of net/http, responses are requested without compression
+type WARCWriter struct {
+	mu       sync.Mutex
+	prefix   string
+	compress bool
+	maxSize  int64
+	segment  int
+	file     *os.File
+	written  int64
+}
+
+// NewWARCWriter returns a writer of the WARC files prefix-00000.warc, prefix-00001.warc, ...
+// a file is created on the first record
+func NewWARCWriter(prefix string) *WARCWriter {
+	return &WARCWriter{prefix: prefix}
+}
+
+// Gzip compresses each record as a gzip member, writing .warc.gz files
+func (w *WARCWriter) Gzip() *WARCWriter {
+	w.compress = true
+	return w
+}
+
+// SetMaxSize starts a new file once a file reaches size bytes, zero keeps a single file
+func (w *WARCWriter) SetMaxSize(size int64) *WARCWriter {
+	w.maxSize = size
+	return w
+}
+
+// SetWARCWriter archives every exchange of the scans to w, each hop of the redirects followed included.
+// responses are requested without compression so their bodies are archived as sent
+func (t *tarantula) SetWARCWriter(w *WARCWriter) *tarantula {
+	t.warc = w
+	t.updateTransport()
+	return t
+}
+
+// archivingTransport archives every exchange of a client following redirects, whose intermediate
+// responses are closed by net/http before they reach the caller
+type archivingTransport struct {
+	transport http.RoundTripper
+	t         *tarantula
+}
+
+func (a *archivingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var ip string
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(connInfo httptrace.GotConnInfo) {
+			ip = connInfo.Conn.RemoteAddr().String()
+			if remoteIP, _, err := net.SplitHostPort(ip); err == nil {
+				ip = remoteIP
+			}
+		},
+	}))
+
+	date := time.Now()
+	resp, err := a.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	a.t.archive(req, resp, body, ip, date)
+	resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errorReader{readErr}))
+	return resp, nil
+}
+
+func (a *archivingTransport) CloseIdleConnections() {
+	if closer, ok := a.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// errorReader returns err, or io.EOF if nil, after the body read by archivingTransport
+type errorReader struct {
+	err error
+}
+
+func (e errorReader) Read([]byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	return 0, io.EOF
+}
+
+// archive writes an exchange to the WARC writer, if any
+func (t *tarantula) archive(req *http.Request, resp *http.Response, body []byte, ip string, date time.Time) {
+	if err := t.warc.WriteExchange(req, resp, body, ip, date); err != nil {
+		t.logger.Warn("archive exchange", "url", req.URL.String(), "error", err)
+	}
+}
+
+// WriteExchange records req and resp, whose body was read into body, as a request and a response record.
+// ip is the address of the server, if known
+func (w *WARCWriter) WriteExchange(req *http.Request, resp *http.Response, body []byte, ip string, date time.Time) error {
+	if w == nil {
+		return nil
+	}
+
+	requestBlock, err := warcRequestBlock(req)
+	if err != nil {
+		return err
+	}
+	responseBlock := warcResponseBlock(resp, body)
+
+	requestID, responseID := warcRecordID(), warcRecordID()
+	target := req.URL.String()
+	headers := func(recordType, recordID, msgType string) [][2]string {
+		fields := [][2]string{
+			{"WARC-Type", recordType},
+			{"WARC-Record-ID", recordID},
+			{"WARC-Date", date.UTC().Format(time.RFC3339)},
+			{"WARC-Target-URI", target},
+		}
+		if ip != "" {
+			fields = append(fields, [2]string{"WARC-IP-Address", ip})
+		}
+		return append(fields, [2]string{"Content-Type", "application/http;msgtype=" + msgType})
+	}
+
+	request := append(headers("request", requestID, "request"), [2]string{"WARC-Concurrent-To", responseID})
+	response := append(headers("response", responseID, "response"), [2]string{"WARC-Concurrent-To", requestID})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.writeRecord(request, requestBlock); err != nil {
+		return err
+	}
+	if err := w.writeRecord(response, responseBlock); err != nil {
+		return err
+	}
+
+	if w.maxSize > 0 && w.written >= w.maxSize {
+		return w.closeSegment()
+	}
+	return nil
+}
+
+// Close closes the current file
+func (w *WARCWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.closeSegment()
+}
+
+// openSegment creates the next file and writes its warcinfo record
+func (w *WARCWriter) openSegment() error {
+	name := fmt.Sprintf("%s-%05d.warc", w.prefix, w.segment)
+	if w.compress {
+		name += ".gz"
+	}
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.written = 0
+	w.segment++
+
+	info := []byte("software: tarantula\r\nformat: WARC File Format 1.1\r\n")
+	return w.writeRecord([][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", warcRecordID()},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", filepath.Base(name)},
+		{"Content-Type", "application/warc-fields"},
+	}, info)
+}
+
+func (w *WARCWriter) closeSegment() error {
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// writeRecord writes a record of fields and block, as a gzip member of its own if compressed
+func (w *WARCWriter) writeRecord(fields [][2]string, block []byte) error {
+	var record bytes.Buffer
+	record.WriteString(warcVersion + "\r\n")
+	for _, field := range fields {
+		record.WriteString(field[0] + ": " + field[1] + "\r\n")
+	}
+	record.WriteString("Content-Length: " + strconv.Itoa(len(block)) + "\r\n\r\n")
+	record.Write(block)
+	record.WriteString("\r\n\r\n")
+
+	out := &countingWriter{w: w.file}
+	if !w.compress {
+		_, err := out.Write(record.Bytes())
+		w.written += out.n
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := gz.Write(record.Bytes()); err != nil {
+		return err
+	}
+	err := gz.Close()
+	w.written += out.n
+	return err
+}
+
+// warcRequestBlock returns the request as written by net/http
+func warcRequestBlock(req *http.Request) ([]byte, error) {
+	var block bytes.Buffer
+	if err := req.Clone(context.Background()).Write(&block); err != nil {
+		return nil, err
+	}
+	return block.Bytes(), nil
+}
+
+func warcResponseBlock(resp *http.Response, body []byte) []byte {
+	var block bytes.Buffer
+	writer := bufio.NewWriter(&block)
+	fmt.Fprintf(writer, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(writer)
+	writer.WriteString("\r\n")
+	writer.Write(body)
+	writer.Flush()
+	return block.Bytes()
+}
+
+// warcRecordID returns a random uuid urn
+func warcRecordID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
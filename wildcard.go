@@ -0,0 +1,247 @@
+package tarantula
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ghaini/tarantula/constants"
+	"github.com/ghaini/tarantula/detector"
+	"github.com/ghaini/tarantula/network"
+)
+
+const (
+	wildcardProbeCount  = 3
+	wildcardLabelLength = 12
+	wildcardLabelChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	// a response whose length differs from another less than this ratio is considered the same page
+	lengthTolerance = 0.05
+)
+
+// wildcardProfile keeps what a non-existent subdomain of a wildcard domain resolves and responds with
+type wildcardProfile struct {
+	ips       map[string]struct{}
+	responses []pageResponse
+	titles    map[string]struct{}
+}
+
+// pageResponse is what a response is compared on by the wildcard and virtual host detection
+type pageResponse struct {
+	statusCode int
+	length     int
+	titleHash  string
+	simhash    uint64
+}
+
+type wildcardDetector struct {
+	mu         sync.RWMutex
+	profiles   map[string]*wildcardProfile
+	detections map[string]*sync.Once
+}
+
+func newWildcardDetector() *wildcardDetector {
+	return &wildcardDetector{
+		profiles:   make(map[string]*wildcardProfile),
+		detections: make(map[string]*sync.Once),
+	}
+}
+
+// detection returns the once of the detection of domain, shared by the workers scanning its subdomains
+func (w *wildcardDetector) detection(domain string) *sync.Once {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	once, exists := w.detections[domain]
+	if !exists {
+		once = &sync.Once{}
+		w.detections[domain] = once
+	}
+	return once
+}
+
+// detectWildcard resolves random labels under domain and, if they are alive, records the wildcard
+// ips and the responses on every scanned port. a domain is detected once, concurrent callers wait for it
+func (t *tarantula) detectWildcard(domain string) {
+	if !t.withWildcard || domain == "" {
+		return
+	}
+
+	t.wildcard.detection(domain).Do(func() {
+		profile := t.wildcardProfile(domain)
+		t.wildcard.mu.Lock()
+		t.wildcard.profiles[domain] = profile
+		t.wildcard.mu.Unlock()
+	})
+}
+
+// wildcardProfile probes random subdomains of domain, nil if they do not resolve
+func (t *tarantula) wildcardProfile(domain string) *wildcardProfile {
+	profile := &wildcardProfile{
+		ips:    make(map[string]struct{}),
+		titles: make(map[string]struct{}),
+	}
+
+	for i := 0; i < wildcardProbeCount; i++ {
+		subdomain := randomLabel(wildcardLabelLength) + "." + domain
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.timeout)*time.Second)
+		record, err := network.LookupDNSThrough(ctx, subdomain, t.dnsServers, t.proxyDialer)
+		cancel()
+		if err != nil || len(record.IPs()) == 0 {
+			continue
+		}
+
+		for _, ip := range record.IPs() {
+			profile.ips[ip] = struct{}{}
+		}
+
+		for _, port := range t.ports {
+			response, ip, ok := t.probeWildcard(subdomain, port)
+			if !ok {
+				continue
+			}
+
+			profile.responses = append(profile.responses, response)
+			profile.titles[wildcardTitleKey(response.statusCode, response.titleHash)] = struct{}{}
+			if ip != "" {
+				profile.ips[ip] = struct{}{}
+			}
+		}
+	}
+
+	if len(profile.ips) == 0 {
+		// not a wildcard domain, remembered as nil to skip detecting again
+		return nil
+	}
+	return profile
+}
+
+// probeWildcard requests subdomain on port over the scheme of the port, or https and then http, and
+// returns the response and the ip it came from. unlike doRequest it applies no filter and records
+// nothing in the stats, metrics or archive of the scan
+func (t *tarantula) probeWildcard(subdomain string, port int) (pageResponse, string, bool) {
+	schemes := []string{constants.HTTPS, constants.HTTP}
+	if scheme, exists := t.portSchemes[port]; exists {
+		schemes = []string{scheme}
+	} else if scheme, exists := constants.PortsProtocols[port]; exists {
+		schemes = []string{scheme}
+	}
+
+	for _, scheme := range schemes {
+		url := scheme + "://" + subdomain
+		if !isDefaultPort(scheme, port) {
+			url += ":" + strconv.Itoa(port)
+		}
+
+		ip := ""
+		trace := &httptrace.ClientTrace{
+			GotConn: func(connInfo httptrace.GotConnInfo) {
+				if remoteIP, _, err := net.SplitHostPort(connInfo.Conn.RemoteAddr().String()); err == nil {
+					ip = remoteIP
+				}
+			},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.timeout)*time.Second)
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "GET", url, nil)
+		if err != nil {
+			cancel()
+			continue
+		}
+		req.Close = !t.keepAlive
+		req.Header.Set("User-Agent", t.userAgents[rand.Intn(len(t.userAgents))])
+
+		resp, err := t.client.Do(req)
+		if err != nil {
+			cancel()
+			continue
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		return newPageResponse(resp.StatusCode, body, resp.Header), ip, true
+	}
+
+	return pageResponse{}, "", false
+}
+
+func newPageResponse(statusCode int, body []byte, header http.Header) pageResponse {
+	return pageResponse{
+		statusCode: statusCode,
+		length:     len(body),
+		titleHash:  hashTitle(detector.ExtractTitle(body, header)),
+		simhash:    detector.Simhash(body),
+	}
+}
+
+// isWildcard reports whether a response matches the wildcard profile of domain: the status and a similar
+// body of a random subdomain, or the same status and title served from one of the wildcard ips
+func (w *wildcardDetector) isWildcard(domain, ip string, response pageResponse) bool {
+	w.mu.RLock()
+	profile := w.profiles[domain]
+	w.mu.RUnlock()
+	if profile == nil {
+		return false
+	}
+
+	for _, wildcardResponse := range profile.responses {
+		if response.isSimilar(wildcardResponse) {
+			return true
+		}
+	}
+
+	if _, exists := profile.ips[ip]; exists {
+		_, exists = profile.titles[wildcardTitleKey(response.statusCode, response.titleHash)]
+		return exists
+	}
+
+	return false
+}
+
+// isSimilar reports whether two responses have the same status, and the same title and about the same
+// length or bodies of the same cluster, so pages echoing the host or a nonce still match
+func (r pageResponse) isSimilar(other pageResponse) bool {
+	if r.statusCode != other.statusCode {
+		return false
+	}
+
+	if r.titleHash == other.titleHash && similarLength(r.length, other.length) {
+		return true
+	}
+
+	return detector.SimhashDistance(r.simhash, other.simhash) <= DefaultClusterDistance
+}
+
+// similarLength reports whether length differs from baseline less than lengthTolerance
+func similarLength(length, baseline int) bool {
+	difference := length - baseline
+	if difference < 0 {
+		difference = -difference
+	}
+
+	return float64(difference) <= float64(baseline)*lengthTolerance
+}
+
+func wildcardTitleKey(statusCode int, titleHash string) string {
+	return strconv.Itoa(statusCode) + "|" + titleHash
+}
+
+func hashTitle(title string) string {
+	sum := md5.Sum([]byte(title))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomLabel(length int) string {
+	label := make([]byte, length)
+	for i := range label {
+		label[i] = wildcardLabelChars[rand.Intn(len(wildcardLabelChars))]
+	}
+	return string(label)
+}